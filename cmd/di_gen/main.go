@@ -0,0 +1,352 @@
+// Command di_gen generates a compile-time dependency injection initializer.
+//
+// Compile-time dependency injection does the work of a DI container when the code is generated instead of when the
+// program runs. The generator reads a provider set, a package-level variable that lists constructors, works out from
+// their parameters and results in which order they must be called, and writes a plain Go function that calls them.
+// The generated code uses no reflection, and the wiring is checked when the code is generated: a parameter that has
+// no provider, two providers for the same type, an interface implemented by several providers, and a cycle between
+// providers are all reported by go generate, with the position of the provider when it is known.
+//
+// It is called by a go:generate directive in the file that declares the provider set, such as the one of the
+// dependency injection example:
+//
+//	//go:generate go run cmd/di_gen/main.go -set Providers -target *Service -func InitializeService -out di_inject.go
+//	var Providers = []any{NewService, NewStdoutLogger}
+//
+// A provider matches a parameter if it returns the type of the parameter, or, for an interface such as Logger, if
+// its result implements the interface. Constructors that return an error are checked, and the error is returned by
+// the initializer. The explanation blocks that open and close the files of this repository are skipped when the
+// input is parsed.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// GenOptions describes the initializer to generate.
+type GenOptions struct {
+	// Input is the Go file that declares the provider set.
+	Input string
+	// Set is the name of the provider set, a package-level variable such as var Providers = []any{NewService, ...}.
+	Set string
+	// Target is the type built by the initializer, such as *Service.
+	Target string
+	// Func is the name of the initializer function.
+	Func string
+}
+
+// genProvider is a constructor of a provider set.
+type genProvider struct {
+	name       string
+	params     []types.Type
+	out        types.Type
+	returnsErr bool
+}
+
+// generator resolves the dependencies of a target type from a provider set.
+type generator struct {
+	pkg       *types.Package
+	providers []*genProvider
+	imports   map[string]string
+	names     map[string]int
+	vars      map[*genProvider]string
+	body      bytes.Buffer
+}
+
+// stripProse removes the explanation blocks of the file, which are not Go code: everything before the package
+// clause, and everything from the first line after it that starts with a backtick.
+func stripProse(src []byte) []byte {
+	var out bytes.Buffer
+	inCode := false
+	for _, line := range strings.SplitAfter(string(src), "\n") {
+		if !inCode {
+			if !strings.HasPrefix(line, "package ") {
+				out.WriteString("\n")
+				continue
+			}
+			inCode = true
+		} else if strings.HasPrefix(line, "`") {
+			break
+		}
+		out.WriteString(line)
+	}
+	return out.Bytes()
+}
+
+// Generate returns the source code of the initializer described by opts.
+// It fails if a dependency has no provider, or if a type has more than one.
+func Generate(opts GenOptions) ([]byte, error) {
+	src, err := os.ReadFile(opts.Input)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, opts.Input, stripProse(src), parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	// The input may use the function being generated, which does not exist yet, so type errors are ignored.
+	info := &types.Info{Uses: map[*ast.Ident]types.Object{}}
+	conf := types.Config{Importer: importer.Default(), Error: func(error) {}}
+	pkg, _ := conf.Check(file.Name.Name, fset, []*ast.File{file}, info)
+
+	g := &generator{pkg: pkg, imports: map[string]string{}, names: map[string]int{}, vars: map[*genProvider]string{}}
+	if err := g.loadSet(fset, file, info, opts.Set); err != nil {
+		return nil, err
+	}
+
+	target, err := types.Eval(fset, pkg, file.End(), opts.Target)
+	if err != nil || !target.IsType() {
+		return nil, fmt.Errorf("target %s is not a type", opts.Target)
+	}
+	result, err := g.resolve(target.Type, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by di_gen from %s; DO NOT EDIT.\n\n", opts.Input)
+	fmt.Fprintf(&out, "package %s\n\n", pkg.Name())
+	if len(g.imports) > 0 {
+		paths := make([]string, 0, len(g.imports))
+		for path := range g.imports {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		out.WriteString("import (\n")
+		for _, path := range paths {
+			fmt.Fprintf(&out, "\t%q\n", path)
+		}
+		out.WriteString(")\n\n")
+	}
+	targetType := g.typeString(target.Type)
+	fmt.Fprintf(&out, "// %s builds a %s with the providers of %s.\n", opts.Func, targetType, opts.Set)
+	fmt.Fprintf(&out, "func %s() (result %s, err error) {\n", opts.Func, targetType)
+	out.Write(g.body.Bytes())
+	fmt.Fprintf(&out, "return %s, nil\n}\n", result)
+
+	return format.Source(out.Bytes())
+}
+
+// loadSet reads the constructors of the provider set called name.
+func (g *generator) loadSet(fset *token.FileSet, file *ast.File, info *types.Info, name string) error {
+	var set *ast.CompositeLit
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.VAR {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, n := range vs.Names {
+				if n.Name == name && i < len(vs.Values) {
+					set, _ = vs.Values[i].(*ast.CompositeLit)
+				}
+			}
+		}
+	}
+	if set == nil {
+		return fmt.Errorf("provider set %s not found: expected var %s = []any{...}", name, name)
+	}
+
+	var errs []error
+	for _, elt := range set.Elts {
+		ident, ok := elt.(*ast.Ident)
+		fn, _ := info.Uses[ident].(*types.Func)
+		if !ok || fn == nil {
+			errs = append(errs, fmt.Errorf("%v: %s is not a function of the package", fset.Position(elt.Pos()), types.ExprString(elt)))
+			continue
+		}
+		p, err := newGenProvider(fn)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%v: %w", fset.Position(elt.Pos()), err))
+			continue
+		}
+		for _, existing := range g.providers {
+			if types.Identical(existing.out, p.out) {
+				errs = append(errs, fmt.Errorf("%v: duplicate provider for %v: %s and %s",
+					fset.Position(elt.Pos()), g.typeString(p.out), existing.name, p.name))
+			}
+		}
+		g.providers = append(g.providers, p)
+	}
+	return errors.Join(errs...)
+}
+
+// newGenProvider checks that fn is a constructor and returns it as a provider.
+func newGenProvider(fn *types.Func) (*genProvider, error) {
+	sig := fn.Type().(*types.Signature)
+	p := &genProvider{name: fn.Name()}
+	results := sig.Results()
+	isErr := func(t types.Type) bool { return types.Identical(t, types.Universe.Lookup("error").Type()) }
+
+	switch {
+	case results.Len() == 1 && !isErr(results.At(0).Type()):
+	case results.Len() == 2 && isErr(results.At(1).Type()):
+		p.returnsErr = true
+	default:
+		return nil, fmt.Errorf("%s: a constructor must return a value, optionally followed by an error", fn.Name())
+	}
+	p.out = results.At(0).Type()
+	for i := 0; i < sig.Params().Len(); i++ {
+		p.params = append(p.params, sig.Params().At(i).Type())
+	}
+	return p, nil
+}
+
+// lookup returns the provider of t. A provider matches if it returns t, or, when t is an interface and no provider
+// returns it, if its result implements t.
+func (g *generator) lookup(t types.Type, requiredBy string) (*genProvider, error) {
+	for _, p := range g.providers {
+		if types.Identical(p.out, t) {
+			return p, nil
+		}
+	}
+
+	var matches []*genProvider
+	if iface, ok := t.Underlying().(*types.Interface); ok {
+		for _, p := range g.providers {
+			if types.Implements(p.out, iface) {
+				matches = append(matches, p)
+			}
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("missing provider for %s (required by %s)", g.typeString(t), requiredBy)
+	case 1:
+		return matches[0], nil
+	default:
+		names := make([]string, len(matches))
+		for i, p := range matches {
+			names[i] = p.name
+		}
+		return nil, fmt.Errorf("duplicate providers for %s (required by %s): %s",
+			g.typeString(t), requiredBy, strings.Join(names, ", "))
+	}
+}
+
+// resolve writes the calls that build t and its dependencies, and returns the variable that holds t.
+// path is the chain of providers being built, used to report cycles.
+func (g *generator) resolve(t types.Type, path []string) (string, error) {
+	requiredBy := "the initializer"
+	if len(path) > 0 {
+		requiredBy = path[len(path)-1]
+	}
+	p, err := g.lookup(t, requiredBy)
+	if err != nil {
+		return "", err
+	}
+	if v, ok := g.vars[p]; ok {
+		return v, nil
+	}
+	for _, name := range path {
+		if name == p.name {
+			return "", fmt.Errorf("dependency cycle: %s -> %s", strings.Join(path, " -> "), p.name)
+		}
+	}
+
+	args := make([]string, len(p.params))
+	for i, param := range p.params {
+		args[i], err = g.resolve(param, append(path, p.name))
+		if err != nil {
+			return "", err
+		}
+	}
+
+	v := g.varName(p.out)
+	call := fmt.Sprintf("%s(%s)", p.name, strings.Join(args, ", "))
+	if p.returnsErr {
+		fmt.Fprintf(&g.body, "%s, err := %s\nif err != nil {\nreturn result, err\n}\n", v, call)
+	} else {
+		fmt.Fprintf(&g.body, "%s := %s\n", v, call)
+	}
+	g.vars[p] = v
+	return v, nil
+}
+
+// varName returns a new variable name for a value of type t, such as stdoutLogger for *StdoutLogger.
+func (g *generator) varName(t types.Type) string {
+	for {
+		ptr, ok := t.(*types.Pointer)
+		if !ok {
+			break
+		}
+		t = ptr.Elem()
+	}
+	base := "v"
+	if named, ok := t.(*types.Named); ok {
+		// Lower the leading initialism as a whole: DB becomes db and HTTPServer becomes httpServer.
+		r := []rune(named.Obj().Name())
+		for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+			if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+				break
+			}
+			r[i] = unicode.ToLower(r[i])
+		}
+		base = string(r)
+	}
+	if token.IsKeyword(base) || base == "result" || base == "err" {
+		base += "Value"
+	}
+	g.names[base]++
+	if n := g.names[base]; n > 1 {
+		return fmt.Sprintf("%s%d", base, n)
+	}
+	return base
+}
+
+// typeString returns t as written in the generated file, recording the imports it needs.
+func (g *generator) typeString(t types.Type) string {
+	return types.TypeString(t, func(p *types.Package) string {
+		if p == g.pkg {
+			return ""
+		}
+		g.imports[p.Path()] = p.Name()
+		return p.Name()
+	})
+}
+
+func main() {
+	var opts GenOptions
+	var output string
+	flag.StringVar(&opts.Input, "in", os.Getenv("GOFILE"), "Go file that declares the provider set")
+	flag.StringVar(&opts.Set, "set", "Providers", "name of the provider set")
+	flag.StringVar(&opts.Target, "target", "", "type built by the initializer, such as *Service")
+	flag.StringVar(&opts.Func, "func", "", "name of the initializer function")
+	flag.StringVar(&output, "out", "", "file to write the initializer to (default: standard output)")
+	flag.Parse()
+
+	if opts.Input == "" || opts.Target == "" || opts.Func == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	code, err := Generate(opts)
+	if err != nil {
+		log.Fatalf("di_gen: %v", err)
+	}
+	if output == "" {
+		os.Stdout.Write(code)
+		return
+	}
+	if err := os.WriteFile(output, code, 0o644); err != nil {
+		log.Fatalf("di_gen: %v", err)
+	}
+}
@@ -0,0 +1,234 @@
+`
+A hot-reloadable configuration is a singleton whose value can change while the program is running. The configuration is loaded from a file, the file is watched for changes, and every new version is validated and swapped in atomically, so readers always see either the old or the new configuration, never a mix of both.
+`
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Config is the configuration of the application. A Config is never modified once it has been loaded.
+type Config struct {
+	Name  string `json:"name"`
+	Port  int    `json:"port"`
+	Debug bool   `json:"debug"`
+}
+
+// Validate checks that the configuration is usable.
+func (c *Config) Validate() error {
+	if c.Name == "" {
+		return errors.New("name is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("port %d is out of range", c.Port)
+	}
+	return nil
+}
+
+// ParseConfig parses a configuration from data. Files ending in .env are parsed as KEY=VALUE lines
+// (APP_NAME, APP_PORT and APP_DEBUG), and every other file is parsed as JSON.
+func ParseConfig(path string, data []byte) (*Config, error) {
+	var c Config
+	if filepath.Ext(path) != ".env" {
+		if err := json.Unmarshal(data, &c); err != nil {
+			return nil, err
+		}
+		return &c, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", line)
+		}
+		value = strings.Trim(strings.TrimSpace(value), `"`)
+
+		var err error
+		switch strings.TrimSpace(key) {
+		case "APP_NAME":
+			c.Name = value
+		case "APP_PORT":
+			c.Port, err = strconv.Atoi(value)
+		case "APP_DEBUG":
+			c.Debug, err = strconv.ParseBool(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return &c, scanner.Err()
+}
+
+// ReloadableConfig is a configuration that is reloaded from its file when the file changes.
+type ReloadableConfig struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	mu          sync.Mutex
+	hash        [sha256.Size]byte
+	subscribers []func(old, new *Config)
+}
+
+// NewReloadableConfig loads the configuration from path. The file must exist and be valid.
+func NewReloadableConfig(path string) (*ReloadableConfig, error) {
+	r := &ReloadableConfig{path: path}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Get returns the current configuration. It is safe to call from several goroutines, even during a reload.
+func (r *ReloadableConfig) Get() *Config {
+	return r.current.Load()
+}
+
+// Subscribe registers a function that is called with the old and the new configuration after every reload.
+func (r *ReloadableConfig) Subscribe(fn func(old, new *Config)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Reload reads the file and, if it changed, validates it and swaps it in, reporting whether the configuration changed.
+// If the file cannot be read, parsed or validated, the current configuration is kept and the error is returned.
+func (r *ReloadableConfig) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return false, fmt.Errorf("reload config: %w", err)
+	}
+	hash := sha256.Sum256(data)
+	if r.current.Load() != nil && hash == r.hash {
+		return false, nil
+	}
+
+	next, err := ParseConfig(r.path, data)
+	if err == nil {
+		err = next.Validate()
+	}
+	if err != nil {
+		return false, fmt.Errorf("reload config %s: %w", r.path, err)
+	}
+
+	r.hash = hash
+	old := r.current.Swap(next)
+	if old != nil {
+		for _, fn := range r.subscribers {
+			fn(old, next)
+		}
+	}
+	return true, nil
+}
+
+// Watch polls the file every interval and reloads it when it changes, until ctx is cancelled.
+// Invalid versions of the file are logged once and ignored.
+func (r *ReloadableConfig) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_, err := r.Reload()
+			if err != nil && err.Error() != last {
+				log.Printf("config: %v (keeping the current configuration)", err)
+			}
+			last = ""
+			if err != nil {
+				last = err.Error()
+			}
+		}
+	}
+}
+
+var (
+	// instance is the configuration singleton.
+	instance *ReloadableConfig
+	// instanceErr is the error of the first load of the configuration.
+	instanceErr error
+	// once guards the creation of instance.
+	once sync.Once
+)
+
+// configPath is the path of the configuration file.
+var configPath = filepath.Join(os.TempDir(), "reloadable-config.json")
+
+// GetInstance returns the configuration singleton, loading it and starting to watch its file on first use.
+func GetInstance() (*ReloadableConfig, error) {
+	once.Do(func() {
+		instance, instanceErr = NewReloadableConfig(configPath)
+		if instanceErr == nil {
+			go instance.Watch(context.Background(), 50*time.Millisecond)
+		}
+	})
+	return instance, instanceErr
+}
+
+func main() {
+	// The file is replaced with a rename, so the watcher never reads a partially written file.
+	write := func(content string) {
+		if err := os.WriteFile(configPath+".tmp", []byte(content), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		if err := os.Rename(configPath+".tmp", configPath); err != nil {
+			log.Fatal(err)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	defer os.Remove(configPath)
+
+	write(`{"name": "My App", "port": 8080}`)
+	config, err := GetInstance()
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Subscribe(func(old, new *Config) {
+		fmt.Printf("Reloaded: %+v -> %+v\n", *old, *new)
+	})
+	fmt.Printf("Config: %+v\n", *config.Get())
+
+	write(`{"name": "My App", "port": 9090, "debug": true}`)
+	write(`{"name": "My App", "port": 0}`)
+	fmt.Printf("Config: %+v\n", *config.Get())
+
+	env, err := ParseConfig("app.env", []byte("# production\nAPP_NAME=\"My App\"\nAPP_PORT=443\n"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("From env file: %+v\n", *env)
+}
+
+`
+In this example, the Config struct is the inner layer, and the ReloadableConfig struct is part of the infrastructure layer. The main function is the outer layer, and it gets the configuration singleton, changes the configuration file once with a valid version and once with an invalid one, and parses an env file.
+
+The GetInstance function creates the configuration singleton on first use, in the same way as in the singleton example, and starts a goroutine that polls the configuration file. The Reload method reads the file, and if its content changed, parses it as JSON (or as KEY=VALUE lines for a .env file) and validates it. A valid configuration is swapped in with an atomic pointer and the subscribers are notified with the old and the new configuration, while an invalid one (such as the one with port 0) is rejected and the current configuration is kept.
+
+Readers call Get every time they need the configuration, and they never see a half-updated configuration, as a Config is never modified once it has been loaded: a reload creates a new Config and replaces the pointer to it in a single step.
+
+This pattern allows the configuration of a long-running process to be changed without restarting it, while protecting the process from an invalid configuration file.
+`
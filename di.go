@@ -22,6 +22,11 @@ func (l *StdoutLogger) Log(message string) {
 	fmt.Println(message)
 }
 
+// NewStdoutLogger creates a new StdoutLogger.
+func NewStdoutLogger() *StdoutLogger {
+	return &StdoutLogger{}
+}
+
 // Service is a component that depends on a logger.
 type Service struct {
 	logger Logger
@@ -37,18 +42,26 @@ func (s *Service) DoSomething() {
 	s.logger.Log("Doing something...")
 }
 
-func main() {
-	logger := &StdoutLogger{}
-	service := NewService(logger)
+// Providers is the provider set of the application, from which cmd/di_gen generates InitializeService.
+// As go generate cannot load this file because of its explanation blocks, run the command of the directive from this
+// directory with GOFILE=di.go.
+//
+//go:generate go run cmd/di_gen/main.go -set Providers -target *Service -func InitializeService -out di_inject.go
+var Providers = []any{NewService, NewStdoutLogger}
 
-	if err := service.DoSomething(); err != nil {
+func main() {
+	service, err := InitializeService()
+	if err != nil {
 		log.Fatal(err)
 	}
+	service.DoSomething()
 }
 
 
 `
 In this example, the Logger interface and the StdoutLogger struct belong to the infrastructure layer, while the Service struct is part of the application's inner layer. The main function is the outer layer, and it depends on the inner layer (Service) to perform some action and log a message.
 
-The Service struct receives its Logger dependency as a constructor parameter, and this dependency is injected into the Service struct by the NewService function. This separation of concerns allows for more maintainable and testable code, as the Service struct can be tested in isolation from the Logger dependency. It also makes it easier to swap out the Logger dependency for a different implementation (e.g., a logger that logs to a file instead of stdout) without affecting the Service struct. `
+The Service struct receives its Logger dependency as a constructor parameter, and this dependency is injected into the Service struct by the NewService function. This separation of concerns allows for more maintainable and testable code, as the Service struct can be tested in isolation from the Logger dependency. It also makes it easier to swap out the Logger dependency for a different implementation (e.g., a logger that logs to a file instead of stdout) without affecting the Service struct.
+
+The Providers variable lists the constructors of the application, and the go:generate directive above it runs cmd/di_gen, which generates the InitializeService function in di_inject.go. InitializeService calls NewStdoutLogger and NewService in the right order, and the main function calls it instead of calling the constructors by hand, so the wiring does not have to be written by hand as the application grows. `
 `
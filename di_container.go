@@ -0,0 +1,1421 @@
+`
+A DI container automates dependency injection. Instead of calling the constructors by hand in the right order, the constructors are registered with the container, which finds out from their parameters which dependencies they need, builds those dependencies first using the other constructors, and passes them in.
+`
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/signal"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// Logger is an interface for logging messages.
+type Logger interface {
+	Log(message string)
+}
+
+// StdoutLogger is a concrete implementation of Logger that logs messages to stdout.
+type StdoutLogger struct{}
+
+// Log logs a message to stdout.
+func (l *StdoutLogger) Log(message string) {
+	fmt.Println(message)
+}
+
+// NewStdoutLogger creates a new StdoutLogger.
+func NewStdoutLogger() *StdoutLogger {
+	return &StdoutLogger{}
+}
+
+// Service is a component that depends on a logger.
+type Service struct {
+	logger Logger
+}
+
+// NewService creates a new Service with the given logger.
+func NewService(logger Logger) *Service {
+	return &Service{logger: logger}
+}
+
+// DoSomething does something and logs a message.
+func (s *Service) DoSomething() {
+	s.logger.Log("Doing something...")
+}
+
+// errorType is the reflect.Type of the error interface.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// contextType is the reflect.Type of the context.Context interface.
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// DefaultHookTimeout is the time a lifecycle hook may run when its provider sets no HookTimeout.
+const DefaultHookTimeout = 15 * time.Second
+
+// MissingBindingError is returned when no provider is registered for a type that is needed.
+type MissingBindingError struct {
+	// Type is the type that has no provider.
+	Type reflect.Type
+	// Name is the name of the binding that is needed, or empty for the default binding of the type.
+	Name string
+	// RequiredBy is the constructor or function that needs the type.
+	RequiredBy string
+}
+
+// Error returns a description of the missing binding.
+func (e *MissingBindingError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("missing binding for %v named %q (required by %s)", e.Type, e.Name, e.RequiredBy)
+	}
+	return fmt.Sprintf("missing binding for %v (required by %s)", e.Type, e.RequiredBy)
+}
+
+// CycleError is returned when building a type depends, directly or indirectly, on the type itself.
+type CycleError struct {
+	// Path is the chain of dependencies that leads back to its first type, which is also its last one.
+	Path []reflect.Type
+}
+
+// Error returns a description of the cycle, such as "dependency cycle: *A -> *B -> *A".
+func (e *CycleError) Error() string {
+	names := make([]string, len(e.Path))
+	for i, t := range e.Path {
+		names[i] = t.String()
+	}
+	return "dependency cycle: " + strings.Join(names, " -> ")
+}
+
+// bindingKey identifies a binding: the default or named binding of a type, or a group of values of a type.
+type bindingKey struct {
+	t     reflect.Type
+	name  string
+	group bool
+}
+
+// String returns the key as written in errors and graphs, such as main.Logger[name="audit"].
+func (k bindingKey) String() string {
+	switch {
+	case k.group:
+		return fmt.Sprintf("%v[group=%q]", k.t, k.name)
+	case k.name != "":
+		return fmt.Sprintf("%v[name=%q]", k.t, k.name)
+	default:
+		return k.t.String()
+	}
+}
+
+// In is embedded in a parameter struct to have the container inject its exported fields one by one instead of the
+// struct itself. A field tagged name:"..." receives a named binding, and a slice field tagged group:"..." receives
+// every value of a group.
+type In struct{}
+
+// inType is the reflect.Type of In.
+var inType = reflect.TypeOf(In{})
+
+// Provider is a parameter that builds the instance of T on the first call to Get instead of when the constructor
+// that takes it is called. It can be injected anywhere a T can, and a dependency through a Provider does not
+// make a cycle, as it is only resolved later.
+type Provider[T any] struct {
+	get func() (T, error)
+}
+
+// Get returns the instance of T, building it if needed. It must not be called from a constructor.
+func (p Provider[T]) Get() (T, error) {
+	if p.get == nil {
+		var zero T
+		return zero, errors.New("provider was not injected by a container")
+	}
+	return p.get()
+}
+
+// elemType returns the type built by the provider.
+func (Provider[T]) elemType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+// withGetter returns a Provider that gets its instance with get.
+func (Provider[T]) withGetter(get func() (reflect.Value, error)) reflect.Value {
+	return reflect.ValueOf(Provider[T]{get: func() (T, error) {
+		v, err := get()
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		t, _ := v.Interface().(T)
+		return t, nil
+	}})
+}
+
+// Optional is a parameter that receives the instance of T if T has a provider, and nothing otherwise, instead of
+// failing with a MissingBindingError.
+type Optional[T any] struct {
+	value T
+	ok    bool
+}
+
+// Get returns the instance of T, and whether there is one.
+func (o Optional[T]) Get() (T, bool) {
+	return o.value, o.ok
+}
+
+// elemType returns the type of the optional value.
+func (Optional[T]) elemType() reflect.Type {
+	return reflect.TypeFor[T]()
+}
+
+// withValue returns an Optional that holds v if ok is true, and nothing otherwise.
+func (Optional[T]) withValue(v reflect.Value, ok bool) reflect.Value {
+	o := Optional[T]{ok: ok}
+	if ok {
+		o.value, _ = v.Interface().(T)
+	}
+	return reflect.ValueOf(o)
+}
+
+// lazyParam is implemented by every Provider type.
+type lazyParam interface {
+	elemType() reflect.Type
+	withGetter(get func() (reflect.Value, error)) reflect.Value
+}
+
+// optionalParam is implemented by every Optional type.
+type optionalParam interface {
+	elemType() reflect.Type
+	withValue(v reflect.Value, ok bool) reflect.Value
+}
+
+// dependency is a binding needed by a parameter, and how the parameter receives it.
+type dependency struct {
+	key bindingKey
+	// wrapper is the Provider or Optional type of the parameter, or nil if the parameter receives the instance.
+	wrapper  reflect.Type
+	lazy     bool
+	optional bool
+}
+
+// newDependency returns the dependency of a parameter of type t on the binding called name.
+func newDependency(t reflect.Type, name string) dependency {
+	switch w := reflect.Zero(t).Interface().(type) {
+	case lazyParam:
+		return dependency{key: bindingKey{t: w.elemType(), name: name}, wrapper: t, lazy: true}
+	case optionalParam:
+		return dependency{key: bindingKey{t: w.elemType(), name: name}, wrapper: t, optional: true}
+	default:
+		return dependency{key: bindingKey{t: t, name: name}}
+	}
+}
+
+// inField is a field of a parameter struct.
+type inField struct {
+	index int
+	dep   dependency
+}
+
+// isIn reports whether t is a parameter struct.
+func isIn(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous && f.Type == inType {
+			return true
+		}
+	}
+	return false
+}
+
+// inFields returns the fields of the parameter struct t and the bindings they need.
+func inFields(t reflect.Type) ([]inField, error) {
+	var fields []inField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type == inType {
+			continue
+		}
+		if !f.IsExported() {
+			return nil, fmt.Errorf("%v: field %s must be exported to be injected", t, f.Name)
+		}
+		name, group := f.Tag.Get("name"), f.Tag.Get("group")
+		switch {
+		case name != "" && group != "":
+			return nil, fmt.Errorf("%v: field %s cannot have both a name and a group", t, f.Name)
+		case group != "":
+			if f.Type.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("%v: field %s of group %q must be a slice", t, f.Name, group)
+			}
+			key := bindingKey{t: f.Type.Elem(), name: group, group: true}
+			fields = append(fields, inField{index: i, dep: dependency{key: key}})
+		default:
+			fields = append(fields, inField{index: i, dep: newDependency(f.Type, name)})
+		}
+	}
+	return fields, nil
+}
+
+// dependencies returns the dependencies of a parameter of type t.
+func dependencies(t reflect.Type) ([]dependency, error) {
+	if !isIn(t) {
+		return []dependency{newDependency(t, "")}, nil
+	}
+	fields, err := inFields(t)
+	if err != nil {
+		return nil, err
+	}
+	deps := make([]dependency, len(fields))
+	for i, f := range fields {
+		deps[i] = f.dep
+	}
+	return deps, nil
+}
+
+// provider is a constructor registered with a container.
+type provider struct {
+	name       string
+	fn         reflect.Value
+	params     []reflect.Type
+	deps       []dependency
+	key        bindingKey
+	out        reflect.Type
+	returnsErr bool
+	onStart    reflect.Value
+	onStop     reflect.Value
+	timeout    time.Duration
+}
+
+// ProvideOption configures how a constructor is registered.
+type ProvideOption func(o *provideOptions) error
+
+// provideOptions are the options of a call to Provide.
+type provideOptions struct {
+	as      reflect.Type
+	name    string
+	group   string
+	onStart reflect.Value
+	onStop  reflect.Value
+	timeout time.Duration
+}
+
+// As binds the result of the constructor to the interface pointed to by iface instead of to its own type,
+// for example As(new(Logger)).
+func As(iface any) ProvideOption {
+	return func(o *provideOptions) error {
+		t := reflect.TypeOf(iface)
+		if t == nil || t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Interface {
+			return fmt.Errorf("As: expected a pointer to an interface, got %v", t)
+		}
+		o.as = t.Elem()
+		return nil
+	}
+}
+
+// Named binds the result of the constructor under name, so several implementations of the same type can be provided.
+// A named binding is injected into the fields of a parameter struct tagged name:"...".
+func Named(name string) ProvideOption {
+	return func(o *provideOptions) error {
+		if name == "" {
+			return errors.New("Named: expected a non-empty name")
+		}
+		o.name = name
+		return nil
+	}
+}
+
+// Group adds the result of the constructor to the group called name instead of binding it. A group can have any
+// number of values, which are injected together into the slice fields of a parameter struct tagged group:"...".
+func Group(name string) ProvideOption {
+	return func(o *provideOptions) error {
+		if name == "" {
+			return errors.New("Group: expected a non-empty name")
+		}
+		o.group = name
+		return nil
+	}
+}
+
+// OnStart registers a hook that is called with the instance of the provider when the container starts.
+// The hook must have the signature func(context.Context, T) error, where T is the type the provider is bound to.
+func OnStart(hook any) ProvideOption {
+	return func(o *provideOptions) error {
+		o.onStart = reflect.ValueOf(hook)
+		return nil
+	}
+}
+
+// OnStop registers a hook that is called with the instance of the provider when the container stops.
+// The hook must have the same signature as an OnStart hook.
+func OnStop(hook any) ProvideOption {
+	return func(o *provideOptions) error {
+		o.onStop = reflect.ValueOf(hook)
+		return nil
+	}
+}
+
+// HookTimeout sets the time each lifecycle hook of the provider may run, instead of DefaultHookTimeout.
+func HookTimeout(d time.Duration) ProvideOption {
+	return func(o *provideOptions) error {
+		if d <= 0 {
+			return fmt.Errorf("HookTimeout: expected a positive duration, got %v", d)
+		}
+		o.timeout = d
+		return nil
+	}
+}
+
+// checkHook checks that hook is a valid lifecycle hook for instances of type t.
+func checkHook(hook reflect.Value, t reflect.Type) error {
+	if !hook.IsValid() {
+		return nil
+	}
+	ht := hook.Type()
+	if ht.Kind() != reflect.Func || ht.NumIn() != 2 || ht.In(0) != contextType || ht.In(1) != t ||
+		ht.NumOut() != 1 || ht.Out(0) != errorType {
+		return fmt.Errorf("expected a hook of type func(context.Context, %v) error, got %v", t, ht)
+	}
+	return nil
+}
+
+// Container builds components from the constructors registered with it.
+// Every binding is built at most once per container, and the same instance is injected everywhere it is needed.
+type Container struct {
+	mu         sync.Mutex
+	providers  map[bindingKey]*provider
+	groups     map[bindingKey][]*provider
+	decorators map[bindingKey][]*provider
+	instances  map[*provider]reflect.Value
+	built      []*provider
+	started    []*provider
+	running    bool
+}
+
+// NewContainer creates a new, empty Container.
+func NewContainer() *Container {
+	return &Container{
+		providers:  map[bindingKey]*provider{},
+		groups:     map[bindingKey][]*provider{},
+		decorators: map[bindingKey][]*provider{},
+		instances:  map[*provider]reflect.Value{},
+	}
+}
+
+// newProvider checks constructor and opts and returns the provider they describe. op is the name of the calling
+// method, used in errors.
+func newProvider(op string, constructor any, opts []ProvideOption) (*provider, error) {
+	var o provideOptions
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return nil, err
+		}
+	}
+
+	fn := reflect.ValueOf(constructor)
+	t := fn.Type()
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s: expected a constructor function, got %v", op, t)
+	}
+	p := &provider{name: funcName(fn), fn: fn}
+
+	switch {
+	case t.NumOut() == 1 && t.Out(0) != errorType:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		p.returnsErr = true
+	default:
+		return nil, fmt.Errorf("%s %s: a constructor must return a value, optionally followed by an error", op, p.name)
+	}
+	p.out = t.Out(0)
+	for i := 0; i < t.NumIn(); i++ {
+		deps, err := dependencies(t.In(i))
+		if err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op, p.name, err)
+		}
+		p.params = append(p.params, t.In(i))
+		p.deps = append(p.deps, deps...)
+	}
+
+	if o.as != nil {
+		if !p.out.Implements(o.as) {
+			return nil, fmt.Errorf("%s %s: %v does not implement %v", op, p.name, p.out, o.as)
+		}
+		p.out = o.as
+	}
+	for _, hook := range []reflect.Value{o.onStart, o.onStop} {
+		if err := checkHook(hook, p.out); err != nil {
+			return nil, fmt.Errorf("%s %s: %w", op, p.name, err)
+		}
+	}
+	p.onStart, p.onStop, p.timeout = o.onStart, o.onStop, o.timeout
+	if p.timeout == 0 {
+		p.timeout = DefaultHookTimeout
+	}
+
+	switch {
+	case o.name != "" && o.group != "":
+		return nil, fmt.Errorf("%s %s: a binding cannot have both a name and a group", op, p.name)
+	case o.group != "":
+		p.key = bindingKey{t: p.out, name: o.group, group: true}
+	default:
+		p.key = bindingKey{t: p.out, name: o.name}
+	}
+	return p, nil
+}
+
+// Provide registers a constructor. A constructor is a function that takes its dependencies as parameters and returns
+// the component it builds, optionally followed by an error. The component is bound to its type, or to the interface
+// given with As, under the name given with Named, or added to the group given with Group.
+func (c *Container) Provide(constructor any, opts ...ProvideOption) error {
+	p, err := newProvider("provide", constructor, opts)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p.key.group {
+		c.groups[p.key] = append(c.groups[p.key], p)
+		return nil
+	}
+	if existing, ok := c.providers[p.key]; ok {
+		return fmt.Errorf("provide %s: %v is already provided by %s", p.name, p.key, existing.name)
+	}
+	c.providers[p.key] = p
+	return nil
+}
+
+// Decorate registers a decorator on a binding. A decorator is a function that takes the instance of the binding as
+// its first parameter, followed by its own dependencies, and returns the instance to inject instead, optionally
+// followed by an error. The binding is the type of the first parameter, with the name given with Named, or every
+// member of the group given with Group. The decorators of a binding are applied in the order they are registered,
+// each one to the result of the previous one, and must be registered before the binding is built.
+func (c *Container) Decorate(decorator any, opts ...ProvideOption) error {
+	d, err := newProvider("decorate", decorator, opts)
+	if err != nil {
+		return err
+	}
+	if len(d.params) == 0 || d.params[0] != d.out {
+		return fmt.Errorf("decorate %s: a decorator must take the instance it decorates as its first parameter "+
+			"and return a value of the same type", d.name)
+	}
+	if d.onStart.IsValid() || d.onStop.IsValid() {
+		return fmt.Errorf("decorate %s: a decorator cannot have lifecycle hooks", d.name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for p := range c.instances {
+		if p.key == d.key {
+			return fmt.Errorf("decorate %s: %v is already built", d.name, d.key)
+		}
+	}
+	c.decorators[d.key] = append(c.decorators[d.key], d)
+	return nil
+}
+
+// dependenciesOf returns the dependencies of p, followed by the dependencies of its decorators.
+// The caller must hold c.mu.
+func (c *Container) dependenciesOf(p *provider) []dependency {
+	deps := append([]dependency{}, p.deps...)
+	for _, d := range c.decorators[p.key] {
+		deps = append(deps, d.deps[1:]...)
+	}
+	return deps
+}
+
+// Override replaces the provider of a binding with constructor until the end of the test, so a test can build the
+// production wiring with a fake for one of its components. The binding is chosen with the same options as Provide
+// (As and Named) and must already have a provider, whose type the replacement must match. Every instance built so far
+// is discarded when the override is registered and again when the test ends, so no instance built with the
+// replacement outlives the test. It panics when called outside a test binary.
+func (c *Container) Override(t testing.TB, constructor any, opts ...ProvideOption) error {
+	mustBeTesting("Container.Override")
+	p, err := newProvider("override", constructor, opts)
+	if err != nil {
+		return err
+	}
+	if p.key.group {
+		return fmt.Errorf("override %s: the members of a group cannot be overridden", p.name)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return fmt.Errorf("override %s: the container is started", p.name)
+	}
+	previous, ok := c.providers[p.key]
+	if !ok {
+		if p.key.t.Kind() != reflect.Interface {
+			return fmt.Errorf("override %s: %v has no provider to override (use As to override an interface)", p.name, p.key)
+		}
+		return fmt.Errorf("override %s: %v has no provider to override", p.name, p.key)
+	}
+
+	c.providers[p.key] = p
+	c.reset()
+	t.Cleanup(func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.providers[p.key] = previous
+		c.reset()
+	})
+	return nil
+}
+
+// reset discards every instance, so they are built again on next use. The caller must hold c.mu.
+func (c *Container) reset() {
+	c.instances = map[*provider]reflect.Value{}
+	c.built = nil
+}
+
+// mustBeTesting panics if the program is not a test binary, so test hooks cannot be used in production.
+func mustBeTesting(name string) {
+	if !testing.Testing() {
+		panic(fmt.Sprintf("di: %s called outside a test binary", name))
+	}
+}
+
+// Invoke calls function with its parameters built by the container.
+// If function returns an error as its last result, Invoke returns it.
+func (c *Container) Invoke(function any) error {
+	fn := reflect.ValueOf(function)
+	t := fn.Type()
+	if t.Kind() != reflect.Func {
+		return fmt.Errorf("invoke: expected a function, got %v", t)
+	}
+
+	args, err := c.resolveArgs(t, funcName(fn))
+	if err != nil {
+		return fmt.Errorf("invoke: %w", err)
+	}
+
+	results := fn.Call(args)
+	if n := len(results); n > 0 && t.Out(n-1) == errorType {
+		if err, _ := results[n-1].Interface().(error); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveArgs returns the values of the parameters of a function of type t. The lock is released by a deferred call,
+// so a constructor that panics does not leave the container locked.
+func (c *Container) resolveArgs(t reflect.Type, requiredBy string) ([]reflect.Value, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	args := make([]reflect.Value, t.NumIn())
+	for i := range args {
+		arg, err := c.resolveParam(t.In(i), requiredBy, nil)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = arg
+	}
+	return args, nil
+}
+
+// resolveParam returns the value of a parameter of type t: the instance bound to t, or a parameter struct with its
+// fields injected. path is the chain of providers being built, used to report cycles. The caller must hold c.mu.
+func (c *Container) resolveParam(t reflect.Type, requiredBy string, path []*provider) (reflect.Value, error) {
+	if !isIn(t) {
+		return c.resolveDependency(newDependency(t, ""), requiredBy, path)
+	}
+	fields, err := inFields(t)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	v := reflect.New(t).Elem()
+	for _, f := range fields {
+		fv, err := c.resolveDependency(f.dep, requiredBy, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.Field(f.index).Set(fv)
+	}
+	return v, nil
+}
+
+// resolveDependency returns the value of a dependency: the instance of its binding, a Provider that resolves the
+// binding later, or an Optional that holds the instance if the binding has a provider. The caller must hold c.mu.
+func (c *Container) resolveDependency(d dependency, requiredBy string, path []*provider) (reflect.Value, error) {
+	switch {
+	case d.lazy:
+		get := func() (reflect.Value, error) {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return c.resolve(d.key, requiredBy, nil)
+		}
+		return reflect.Zero(d.wrapper).Interface().(lazyParam).withGetter(get), nil
+	case d.optional:
+		w := reflect.Zero(d.wrapper).Interface().(optionalParam)
+		if _, ok := c.providers[d.key]; !ok && !d.key.group {
+			return w.withValue(reflect.Value{}, false), nil
+		}
+		v, err := c.resolve(d.key, requiredBy, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return w.withValue(v, true), nil
+	default:
+		return c.resolve(d.key, requiredBy, path)
+	}
+}
+
+// resolve returns the instance of a binding, or a slice of the instances of a group.
+// The caller must hold c.mu.
+func (c *Container) resolve(key bindingKey, requiredBy string, path []*provider) (reflect.Value, error) {
+	if key.group {
+		members := c.groups[key]
+		values := reflect.MakeSlice(reflect.SliceOf(key.t), 0, len(members))
+		for _, p := range members {
+			v, err := c.build(p, path)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			values = reflect.Append(values, v)
+		}
+		return values, nil
+	}
+
+	p, ok := c.providers[key]
+	if !ok {
+		return reflect.Value{}, &MissingBindingError{Type: key.t, Name: key.name, RequiredBy: requiredBy}
+	}
+	return c.build(p, path)
+}
+
+// build returns the instance of p, calling its constructor and building its dependencies if needed.
+// The caller must hold c.mu.
+func (c *Container) build(p *provider, path []*provider) (reflect.Value, error) {
+	if v, ok := c.instances[p]; ok {
+		return v, nil
+	}
+	if err := cycleError(path, p); err != nil {
+		return reflect.Value{}, err
+	}
+
+	path = append(path, p)
+	args := make([]reflect.Value, len(p.params))
+	for i, param := range p.params {
+		arg, err := c.resolveParam(param, p.name, path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		args[i] = arg
+	}
+
+	results := p.fn.Call(args)
+	if p.returnsErr {
+		if err, _ := results[1].Interface().(error); err != nil {
+			return reflect.Value{}, fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+
+	v := results[0]
+	if v.Type() != p.out {
+		// The constructor is bound to an interface: convert its result to it.
+		v = v.Convert(p.out)
+	}
+
+	for _, d := range c.decorators[p.key] {
+		args := []reflect.Value{v}
+		for _, param := range d.params[1:] {
+			arg, err := c.resolveParam(param, d.name, path)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			args = append(args, arg)
+		}
+		results := d.fn.Call(args)
+		if d.returnsErr {
+			if err, _ := results[1].Interface().(error); err != nil {
+				return reflect.Value{}, fmt.Errorf("%s: %w", d.name, err)
+			}
+		}
+		v = results[0]
+	}
+	c.instances[p] = v
+	c.built = append(c.built, p)
+	return v, nil
+}
+
+// Start builds every provider that has lifecycle hooks, and calls the OnStart hooks of the instances built so far in
+// the order they were built, so an instance is started after its dependencies. If a hook fails, the instances already
+// started are stopped and the error is returned. Instances built after Start are not started, and Start fails if
+// the container is already started and has not been stopped.
+func (c *Container) Start(ctx context.Context) error {
+	built, err := c.buildHooked()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range built {
+		if p.onStart.IsValid() {
+			if err := c.runHook(ctx, p, p.onStart); err != nil {
+				return errors.Join(fmt.Errorf("start %s: %w", p.name, err), c.Stop(ctx))
+			}
+		}
+		c.mu.Lock()
+		c.started = append(c.started, p)
+		c.mu.Unlock()
+	}
+	return nil
+}
+
+// buildHooked marks the container as started and builds every provider that has lifecycle hooks, so they are
+// started even if nothing has needed them yet. It returns the providers built so far, in the order they were built.
+func (c *Container) buildHooked() ([]*provider, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.running {
+		return nil, errors.New("start: the container is already started")
+	}
+	for _, p := range c.sortedProviders() {
+		if p.onStart.IsValid() || p.onStop.IsValid() {
+			if _, err := c.build(p, nil); err != nil {
+				return nil, fmt.Errorf("start: %w", err)
+			}
+		}
+	}
+	c.running = true
+	return append([]*provider{}, c.built...), nil
+}
+
+// Stop calls the OnStop hooks of the started instances in reverse order, so an instance is stopped before its
+// dependencies. Every hook is called even if some fail, and their errors are joined. The container can then be
+// started again.
+func (c *Container) Stop(ctx context.Context) error {
+	c.mu.Lock()
+	started := c.started
+	c.started = nil
+	c.running = false
+	c.mu.Unlock()
+
+	var errs []error
+	for i := len(started) - 1; i >= 0; i-- {
+		p := started[i]
+		if p.onStop.IsValid() {
+			if err := c.runHook(ctx, p, p.onStop); err != nil {
+				errs = append(errs, fmt.Errorf("stop %s: %w", p.name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// runHook calls hook with the instance of p, and gives up when the timeout of p expires.
+func (c *Container) runHook(ctx context.Context, p *provider, hook reflect.Value) error {
+	c.mu.Lock()
+	v := c.instances[p]
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		err, _ := hook.Call([]reflect.Value{reflect.ValueOf(ctx), v})[0].Interface().(error)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// App runs the components of a container until the process is asked to exit.
+type App struct {
+	container *Container
+}
+
+// NewApp creates a new App for the components of container.
+func NewApp(container *Container) *App {
+	return &App{container: container}
+}
+
+// Run starts the container, waits until ctx is done or the process receives SIGINT or SIGTERM, and stops the
+// container. The stop hooks are not cancelled by the signal, only by their own timeouts.
+func (a *App) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := a.container.Start(ctx); err != nil {
+		return err
+	}
+	<-ctx.Done()
+	return a.container.Stop(context.WithoutCancel(ctx))
+}
+
+// cycleError returns a CycleError if p is already in path, and nil otherwise.
+func cycleError(path []*provider, p *provider) error {
+	for i, seen := range path {
+		if seen == p {
+			var cycle []reflect.Type
+			for _, q := range path[i:] {
+				cycle = append(cycle, q.out)
+			}
+			return &CycleError{Path: append(cycle, p.out)}
+		}
+	}
+	return nil
+}
+
+// Validate checks the registered providers without calling them, and returns a MissingBindingError for every
+// dependency that has no provider, a CycleError for every cycle, and an error for every decorator of a binding that
+// has no provider, which would never be applied.
+func (c *Container) Validate() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	done := map[*provider]bool{}
+	var visit func(p *provider, path []*provider)
+	visit = func(p *provider, path []*provider) {
+		if done[p] {
+			return
+		}
+		if err := cycleError(path, p); err != nil {
+			errs = append(errs, err)
+			return
+		}
+		path = append(path, p)
+		for _, dep := range c.dependenciesOf(p) {
+			if dep.key.group {
+				for _, q := range c.groups[dep.key] {
+					visit(q, path)
+				}
+				continue
+			}
+			q, ok := c.providers[dep.key]
+			if !ok {
+				if !dep.optional {
+					errs = append(errs, &MissingBindingError{Type: dep.key.t, Name: dep.key.name, RequiredBy: p.name})
+				}
+				continue
+			}
+			// A Provider is resolved after its constructor has returned, so it cannot make a cycle. Its binding is
+			// checked on its own, as every provider is.
+			if !dep.lazy {
+				visit(q, path)
+			}
+		}
+		done[p] = true
+	}
+	for _, p := range c.sortedProviders() {
+		visit(p, nil)
+	}
+
+	keys := make([]bindingKey, 0, len(c.decorators))
+	for key := range c.decorators {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	for _, key := range keys {
+		_, ok := c.providers[key]
+		if key.group {
+			ok = len(c.groups[key]) > 0
+		}
+		if !ok {
+			for _, d := range c.decorators[key] {
+				errs = append(errs, fmt.Errorf("decorate %s: %v has no provider", d.name, key))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// GraphNode is a binding of a dependency graph.
+type GraphNode struct {
+	// Type is the name of the type of the binding, followed by its name or group if it has one.
+	Type string `json:"type"`
+	// Provider is the name of the constructor of the binding (or the names of the constructors of a group,
+	// separated by commas), or empty if the binding has no provider.
+	Provider string `json:"provider,omitempty"`
+	// Built reports whether the instances of the binding have been built.
+	Built bool `json:"built"`
+}
+
+// GraphEdge is a dependency of a dependency graph: the constructor of From needs a To.
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	// Lazy reports whether the dependency is injected through a Provider.
+	Lazy bool `json:"lazy,omitempty"`
+	// Optional reports whether the dependency is injected through an Optional.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// Graph is the dependency graph of a container.
+type Graph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
+// Graph returns the dependency graph of the registered providers, sorted by binding.
+// Dependencies that have no provider are included as nodes without a provider.
+func (c *Container) Graph() Graph {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var g Graph
+	nodes := map[string]int{}
+	var deps []bindingKey
+	for _, p := range c.sortedProviders() {
+		_, built := c.instances[p]
+		id := p.key.String()
+		if i, ok := nodes[id]; ok {
+			// Another member of the same group.
+			g.Nodes[i].Provider += ", " + p.name
+			g.Nodes[i].Built = g.Nodes[i].Built && built
+		} else {
+			nodes[id] = len(g.Nodes)
+			g.Nodes = append(g.Nodes, GraphNode{Type: id, Provider: p.name, Built: built})
+		}
+		for _, dep := range c.dependenciesOf(p) {
+			g.Edges = append(g.Edges, GraphEdge{From: id, To: dep.key.String(), Lazy: dep.lazy, Optional: dep.optional})
+			deps = append(deps, dep.key)
+		}
+	}
+	for _, dep := range deps {
+		if _, ok := nodes[dep.String()]; !ok {
+			nodes[dep.String()] = len(g.Nodes)
+			g.Nodes = append(g.Nodes, GraphNode{Type: dep.String()})
+		}
+	}
+	sort.Slice(g.Nodes, func(i, j int) bool { return g.Nodes[i].Type < g.Nodes[j].Type })
+	return g
+}
+
+// WriteJSON writes the graph as JSON.
+func (g Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+// WriteDOT writes the graph in the DOT language of Graphviz. Types without a provider and lazy dependencies are drawn
+// dashed, and optional dependencies are drawn dotted.
+func (g Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph dependencies {\n")
+	for _, n := range g.Nodes {
+		if n.Provider == "" {
+			fmt.Fprintf(&b, "\t%q [style=dashed];\n", n.Type)
+		} else {
+			fmt.Fprintf(&b, "\t%q [label=%q];\n", n.Type, n.Type+"\n"+n.Provider)
+		}
+	}
+	for _, e := range g.Edges {
+		switch {
+		case e.Lazy:
+			fmt.Fprintf(&b, "\t%q -> %q [style=dashed];\n", e.From, e.To)
+		case e.Optional:
+			fmt.Fprintf(&b, "\t%q -> %q [style=dotted];\n", e.From, e.To)
+		default:
+			fmt.Fprintf(&b, "\t%q -> %q;\n", e.From, e.To)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sortedProviders returns every provider, including the members of groups, sorted by binding and then by name.
+// The caller must hold c.mu.
+func (c *Container) sortedProviders() []*provider {
+	providers := make([]*provider, 0, len(c.providers))
+	for _, p := range c.providers {
+		providers = append(providers, p)
+	}
+	for _, members := range c.groups {
+		providers = append(providers, members...)
+	}
+	sort.Slice(providers, func(i, j int) bool {
+		ki, kj := providers[i].key.String(), providers[j].key.String()
+		if ki != kj {
+			return ki < kj
+		}
+		return providers[i].name < providers[j].name
+	})
+	return providers
+}
+
+// funcName returns the name of a function, without its package path.
+func funcName(fn reflect.Value) string {
+	f := runtime.FuncForPC(fn.Pointer())
+	if f == nil {
+		return fn.Type().String()
+	}
+	name := f.Name()
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+// Database is a component that nothing provides in this example.
+type Database struct{}
+
+// Cache is a cache in front of a Repository.
+type Cache struct {
+	repository *Repository
+}
+
+// NewCache creates a new Cache.
+func NewCache(r *Repository) *Cache {
+	return &Cache{repository: r}
+}
+
+// Repository is a repository that sends notifications when it changes.
+type Repository struct {
+	notifier *Notifier
+}
+
+// NewRepository creates a new Repository.
+func NewRepository(n *Notifier) *Repository {
+	return &Repository{notifier: n}
+}
+
+// Notifier sends notifications, and invalidates the Cache that depends on it, which makes a cycle.
+type Notifier struct {
+	cache *Cache
+}
+
+// NewNotifier creates a new Notifier.
+func NewNotifier(c *Cache) *Notifier {
+	return &Notifier{cache: c}
+}
+
+// AuditLogger is a Logger for the audit trail, which is kept apart from the logs of the application.
+type AuditLogger struct{}
+
+// Log logs a message to the audit trail.
+func (l *AuditLogger) Log(message string) {
+	fmt.Println("AUDIT:", message)
+}
+
+// NewAuditLogger creates a new AuditLogger.
+func NewAuditLogger() *AuditLogger {
+	return &AuditLogger{}
+}
+
+// Handler is an HTTP handler that knows the pattern it serves.
+type Handler interface {
+	http.Handler
+	Pattern() string
+}
+
+// HealthHandler reports that the server is up.
+type HealthHandler struct{}
+
+// NewHealthHandler creates a new HealthHandler.
+func NewHealthHandler() *HealthHandler {
+	return &HealthHandler{}
+}
+
+// Pattern returns the pattern served by the handler.
+func (h *HealthHandler) Pattern() string {
+	return "GET /health"
+}
+
+// ServeHTTP writes "ok".
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, "ok")
+}
+
+// UsersHandler lists the users, and records every access in the audit trail.
+type UsersHandler struct {
+	audit Logger
+}
+
+// UsersParams are the dependencies of a UsersHandler.
+type UsersParams struct {
+	In
+	Audit Logger `name:"audit"`
+}
+
+// NewUsersHandler creates a new UsersHandler.
+func NewUsersHandler(p UsersParams) *UsersHandler {
+	return &UsersHandler{audit: p.Audit}
+}
+
+// Pattern returns the pattern served by the handler.
+func (h *UsersHandler) Pattern() string {
+	return "GET /users"
+}
+
+// ServeHTTP writes the list of users.
+func (h *UsersHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.audit.Log("users listed")
+	io.WriteString(w, "alice, bob")
+}
+
+// ServerParams are the dependencies of a Server.
+type ServerParams struct {
+	In
+	Logger   Logger
+	Handlers []Handler `group:"handlers"`
+}
+
+// NewServer creates a new http.ServeMux that serves every handler of the "handlers" group.
+func NewServer(p ServerParams) *http.ServeMux {
+	mux := http.NewServeMux()
+	for _, h := range p.Handlers {
+		p.Logger.Log("Serving " + h.Pattern())
+		mux.Handle(h.Pattern(), h)
+	}
+	return mux
+}
+
+// RecordingLogger is a fake Logger for tests, which records the messages instead of printing them.
+type RecordingLogger struct {
+	Messages []string
+}
+
+// NewRecordingLogger creates a new RecordingLogger.
+func NewRecordingLogger() *RecordingLogger {
+	return &RecordingLogger{}
+}
+
+// Log records a message.
+func (l *RecordingLogger) Log(message string) {
+	l.Messages = append(l.Messages, message)
+}
+
+// ReportGenerator generates reports. It is expensive to build, so it is only built when a report is needed.
+type ReportGenerator struct{}
+
+// NewReportGenerator creates a new ReportGenerator.
+func NewReportGenerator() *ReportGenerator {
+	fmt.Println("Building ReportGenerator")
+	return &ReportGenerator{}
+}
+
+// Metrics records metrics. It is only provided in the deployments that collect metrics.
+type Metrics struct{}
+
+// Exporter exports reports on demand.
+type Exporter struct {
+	reports Provider[*ReportGenerator]
+	metrics Optional[*Metrics]
+}
+
+// NewExporter creates a new Exporter.
+func NewExporter(reports Provider[*ReportGenerator], metrics Optional[*Metrics]) *Exporter {
+	return &Exporter{reports: reports, metrics: metrics}
+}
+
+// Export generates a report.
+func (e *Exporter) Export() error {
+	if _, ok := e.metrics.Get(); !ok {
+		fmt.Println("Exporting without metrics")
+	}
+	if _, err := e.reports.Get(); err != nil {
+		return err
+	}
+	fmt.Println("Report exported")
+	return nil
+}
+
+// PrefixLogger is a Logger that adds a prefix to the messages of another Logger.
+type PrefixLogger struct {
+	prefix string
+	next   Logger
+}
+
+// Log logs a message with the prefix.
+func (l *PrefixLogger) Log(message string) {
+	l.next.Log(l.prefix + message)
+}
+
+// MessageCounter counts the messages that are logged.
+type MessageCounter struct {
+	count int
+}
+
+// NewMessageCounter creates a new MessageCounter.
+func NewMessageCounter() *MessageCounter {
+	return &MessageCounter{}
+}
+
+// CountingLogger is a Logger that counts the messages of another Logger.
+type CountingLogger struct {
+	counter *MessageCounter
+	next    Logger
+}
+
+// Log counts and logs a message.
+func (l *CountingLogger) Log(message string) {
+	l.counter.count++
+	l.next.Log(message)
+}
+
+func main() {
+	container := NewContainer()
+
+	err := container.Provide(NewService,
+		OnStart(func(ctx context.Context, s *Service) error {
+			s.logger.Log("Service: opening connections")
+			return nil
+		}),
+		OnStop(func(ctx context.Context, s *Service) error {
+			s.logger.Log("Service: flushing")
+			<-ctx.Done()
+			return ctx.Err()
+		}),
+		HookTimeout(50*time.Millisecond),
+	)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	err = container.Provide(NewStdoutLogger, As(new(Logger)),
+		OnStop(func(ctx context.Context, l Logger) error {
+			l.Log("Logger: closing")
+			return nil
+		}),
+	)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	err = container.Invoke(func(service *Service) {
+		service.DoSomething()
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	err = container.Invoke(func(db *Database) {})
+	var missing *MissingBindingError
+	if errors.As(err, &missing) {
+		fmt.Println("Error:", err)
+	}
+
+	container.Graph().WriteDOT(os.Stdout)
+
+	cyclic := NewContainer()
+	cyclic.Provide(NewCache)
+	cyclic.Provide(NewRepository)
+	cyclic.Provide(NewNotifier)
+	if err := cyclic.Validate(); err != nil {
+		fmt.Println("Error:", err)
+	}
+	err = cyclic.Invoke(func(c *Cache) {})
+	var cycle *CycleError
+	if errors.As(err, &cycle) {
+		fmt.Println("Error:", err)
+	}
+	cyclic.Graph().WriteJSON(os.Stdout)
+
+	web := NewContainer()
+	web.Provide(NewStdoutLogger, As(new(Logger)))
+	web.Provide(NewAuditLogger, As(new(Logger)), Named("audit"))
+	web.Provide(NewHealthHandler, As(new(Handler)), Group("handlers"))
+	web.Provide(NewUsersHandler, As(new(Handler)), Group("handlers"))
+	web.Provide(NewServer)
+	err = web.Invoke(func(mux *http.ServeMux) {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest("GET", "/users", nil))
+		fmt.Println("GET /users:", rec.Body.String())
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+	web.Graph().WriteDOT(os.Stdout)
+
+	decorated := NewContainer()
+	decorated.Provide(NewService)
+	decorated.Provide(NewStdoutLogger, As(new(Logger)))
+	decorated.Provide(NewMessageCounter)
+	decorated.Decorate(func(l Logger) Logger {
+		return &PrefixLogger{prefix: "[service] ", next: l}
+	})
+	decorated.Decorate(func(l Logger, counter *MessageCounter) Logger {
+		return &CountingLogger{counter: counter, next: l}
+	})
+	err = decorated.Invoke(func(s *Service, counter *MessageCounter) {
+		s.DoSomething()
+		s.DoSomething()
+		fmt.Println("Messages logged:", counter.count)
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	reporting := NewContainer()
+	reporting.Provide(NewReportGenerator)
+	reporting.Provide(NewExporter)
+	err = reporting.Invoke(func(e *Exporter) error {
+		fmt.Println("Exporter built")
+		return e.Export()
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := NewApp(container).Run(ctx); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Error:", r)
+		}
+	}()
+	container.Override(nil, NewRecordingLogger, As(new(Logger)))
+}
+
+`
+In this example, the Logger interface, the StdoutLogger struct, the Service struct and the NewService function are the same as in the dependency injection example, while the Container struct is part of the infrastructure layer. The main function is the outer layer, and instead of calling NewService with a logger by hand, it registers the constructors with the container and lets the container call them.
+
+The Provide method registers a constructor, and uses reflection to find out the type it builds (or the interface given with As) and the types of its parameters. The Invoke method calls a function with its parameters built by the container: to build a *Service, the container finds that NewService needs a Logger, builds the Logger with NewStdoutLogger, and passes it to NewService. Every type is built at most once, so every component that depends on a Logger gets the same one.
+
+When a type has no provider, the container returns a MissingBindingError that names the type and the constructor or function that needs it, such as the Database needed by the last function in the example. Registering two providers for the same type is an error as well.
+
+A constructor must not depend on its own type, directly or indirectly. The resolve method keeps the chain of types being built, and when a type appears twice in it, it returns a CycleError with the full path of the cycle, such as the Cache that needs a Repository, that needs a Notifier, that needs the Cache. The Validate method checks every provider for missing bindings and cycles, and every decorator for a binding without a provider, without calling any constructor, so the wiring can be checked when the application starts, before anything is built.
+
+The Graph method returns the dependency graph of the container, with a node for every type and the constructor that provides it, and an edge for every parameter of a constructor. The graph can be written as JSON, or in the DOT language to be drawn with Graphviz for the documentation of the application.
+
+A type can have several bindings. The Named option binds a constructor under a name, such as the AuditLogger that is bound to Logger under the name "audit" next to the StdoutLogger, and the Group option adds a constructor to a group, which can have any number of members, such as the "handlers" group of HTTP handlers. A constructor asks for a named binding or a group with a parameter struct that embeds In: every exported field of the struct is injected on its own, a field tagged name:"audit" receives the named binding, and a slice field tagged group:"handlers" receives the instances of every member of the group, in the order they were provided.
+
+Components often need to do some work when the application starts and when it stops, such as opening and flushing connections. The OnStart and OnStop options register hooks alongside a provider, and the container records the order in which it builds the instances. The Start method first builds every provider that has hooks, so they are started even if nothing has been invoked yet, and then calls the start hooks in the order the instances were built, so the Logger is started before the Service that uses it, and the Stop method calls the stop hooks in reverse order, so the Service is flushed while its Logger is still open. Every hook runs with its own timeout (DefaultHookTimeout, or the one given with HookTimeout), so a hook that hangs, such as the stop hook of the Service in the example, cannot block the shutdown. Starting a container that is already started is an error, so no hook runs twice. The App struct starts the container, waits for SIGINT or SIGTERM (or for its context to be done), and stops the container.
+
+Some dependencies are expensive to build, or only present in some deployments. A parameter of type Provider[T] receives a Provider instead of the instance of T, and the instance is built on the first call to its Get method, so the ReportGenerator is only built when the Exporter exports its first report. A dependency through a Provider does not make a cycle, as it is resolved after the constructor has returned. A parameter of type Optional[T] receives the instance of T if T has a provider, and nothing otherwise, such as the Metrics that have no provider in the example, instead of failing with a MissingBindingError. Both also work for the fields of a parameter struct, with a name tag for a named binding, and they are drawn dashed and dotted in the DOT graph.
+
+The Decorate method registers a decorator on a binding, which wraps every instance of the binding with cross-cutting behavior such as timing, logging or retries, without changing the constructor of the binding or the components that depend on it. A decorator takes the instance as its first parameter and returns the instance to inject instead, and it can take other dependencies, such as the MessageCounter of the CountingLogger in the example. The decorators of a binding are applied in the order they are registered, each one to the result of the previous one, so the Service gets a CountingLogger that wraps a PrefixLogger that wraps the StdoutLogger. With Named or Group, a decorator applies to a named binding or to every member of a group.
+
+A test can build the production wiring and replace one binding with a fake through the Override method, which takes the same options as Provide to choose the binding, such as a test of the Service that records the messages of its Logger:
+
+	func TestService(t *testing.T) {
+		container := NewProductionContainer()
+		if err := container.Override(t, NewRecordingLogger, As(new(Logger))); err != nil {
+			t.Fatal(err)
+		}
+		container.Invoke(func(s *Service, l Logger) {
+			s.DoSomething()
+			// l is the RecordingLogger.
+		})
+	}
+
+Override fails if the binding has no provider, or if the replacement does not have the type of the binding, so a fake cannot silently add a binding that the production wiring does not have. The previous provider is restored with t.Cleanup when the test ends, and the instances built during the test are discarded, so the fake cannot leak into the next test that uses the same container. Like the test hooks of the singleton example, Override panics when called outside a test binary (as the main function shows).
+
+This pattern allows the wiring of the application to be described once, by the constructors themselves, so adding a dependency to a component only requires adding a parameter to its constructor.
+`
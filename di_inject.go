@@ -0,0 +1,10 @@
+// Code generated by di_gen from di.go; DO NOT EDIT.
+
+package main
+
+// InitializeService builds a *Service with the providers of Providers.
+func InitializeService() (result *Service, err error) {
+	stdoutLogger := NewStdoutLogger()
+	service := NewService(stdoutLogger)
+	return service, nil
+}
@@ -0,0 +1,479 @@
+`
+An event store is an append-only log of the state changes of a system. Instead of overwriting the current state, every change is appended as an event, and the state (or any read model derived from it) can be rebuilt at any time by replaying the events from the beginning, from an offset, or from a point in time.
+`
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SyncPolicy determines when appended records are flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways flushes the segment after every appended record.
+	SyncAlways SyncPolicy = iota
+	// SyncEveryN flushes the segment after every SyncEvery appended records.
+	SyncEveryN
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+// recordHeaderSize is the size of a record header: payload length (4 bytes), CRC-32 of the timestamp and payload (4 bytes) and timestamp (8 bytes).
+const recordHeaderSize = 16
+
+// segmentSuffix is the file name suffix of segment files. A segment file is named after the offset of its first record.
+const segmentSuffix = ".log"
+
+// ErrCorruptSegment is returned when a record that is not at the tail of the log fails validation.
+var ErrCorruptSegment = errors.New("event store: corrupt segment")
+
+// errTornRecord is returned when a record is incomplete or fails its CRC check.
+var errTornRecord = errors.New("event store: torn record")
+
+// Record is an event read back from the event store.
+type Record struct {
+	Offset uint64
+	Time   time.Time
+	Data   []byte
+}
+
+// EventStoreOptions configures an EventStore.
+type EventStoreOptions struct {
+	// Dir is the directory that holds the segment files.
+	Dir string
+	// MaxSegmentBytes is the size after which a new segment file is started.
+	MaxSegmentBytes int64
+	// Sync is the fsync policy of the store.
+	Sync SyncPolicy
+	// SyncEvery is the number of records between flushes when Sync is SyncEveryN.
+	SyncEvery int
+}
+
+// EventStore is an append-only log of events stored in segment files on local disk.
+type EventStore struct {
+	mu         sync.Mutex
+	opts       EventStoreOptions
+	now        func() time.Time
+	segments   []uint64
+	active     *os.File
+	activeSize int64
+	next       uint64
+	unsynced   int
+	// failed is the error that left the active segment in an unknown state, after which every Append fails.
+	failed error
+}
+
+// OpenEventStore opens the event store in opts.Dir, creating it if needed.
+// A torn record at the tail of the last segment, left by a crash during an append, is truncated away.
+func OpenEventStore(opts EventStoreOptions) (*EventStore, error) {
+	if opts.MaxSegmentBytes <= 0 {
+		opts.MaxSegmentBytes = 64 << 20
+	}
+	if opts.Sync == SyncEveryN && opts.SyncEvery <= 0 {
+		opts.SyncEvery = 1
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &EventStore{opts: opts, now: time.Now}
+	segments, err := s.listSegments()
+	if err != nil {
+		return nil, err
+	}
+	if len(segments) == 0 {
+		segments = []uint64{0}
+	}
+	s.segments = segments
+
+	for i, base := range segments {
+		last := i == len(segments)-1
+		size, count, err := scanSegment(s.segmentPath(base), base, nil)
+		if err != nil && !(last && errors.Is(err, errTornRecord)) {
+			return nil, fmt.Errorf("%w: %s: %v", ErrCorruptSegment, s.segmentPath(base), err)
+		}
+		if last {
+			if err := s.openActive(base, size); err != nil {
+				return nil, err
+			}
+			s.next = base + count
+		}
+	}
+
+	return s, nil
+}
+
+// Append appends an event to the log and returns its offset.
+// If flushing the record fails, the record may or may not have been persisted, so Append returns the error and
+// the store refuses every later append: retrying could store the event twice.
+func (s *EventStore) Append(data []byte) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.active == nil {
+		return 0, os.ErrClosed
+	}
+	if s.failed != nil {
+		return 0, fmt.Errorf("event store failed: %w", s.failed)
+	}
+
+	size := int64(recordHeaderSize + len(data))
+	if s.activeSize > 0 && s.activeSize+size > s.opts.MaxSegmentBytes {
+		if err := s.roll(); err != nil {
+			return 0, err
+		}
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(data)))
+	binary.BigEndian.PutUint64(buf[8:16], uint64(s.now().UnixNano()))
+	copy(buf[recordHeaderSize:], data)
+	binary.BigEndian.PutUint32(buf[4:8], crc32.ChecksumIEEE(buf[8:]))
+
+	if _, err := s.active.Write(buf); err != nil {
+		// Remove the part of the record that was written, so the next record follows the last complete one.
+		if terr := s.active.Truncate(s.activeSize); terr != nil {
+			s.failed = errors.Join(err, terr)
+		} else if _, serr := s.active.Seek(s.activeSize, io.SeekStart); serr != nil {
+			s.failed = errors.Join(err, serr)
+		}
+		return 0, err
+	}
+	s.activeSize += size
+
+	offset := s.next
+	s.next++
+
+	s.unsynced++
+	switch {
+	case s.opts.Sync == SyncAlways,
+		s.opts.Sync == SyncEveryN && s.unsynced >= s.opts.SyncEvery:
+		if err := s.active.Sync(); err != nil {
+			s.failed = err
+			return 0, fmt.Errorf("sync record %d: %w", offset, err)
+		}
+		s.unsynced = 0
+	}
+
+	return offset, nil
+}
+
+// Sync flushes the active segment to stable storage. If it fails, the store refuses every later append, as Append
+// does when its own flush fails.
+func (s *EventStore) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil {
+		return os.ErrClosed
+	}
+	s.unsynced = 0
+	if err := s.active.Sync(); err != nil {
+		s.failed = err
+		return err
+	}
+	return nil
+}
+
+// Close flushes and closes the event store.
+func (s *EventStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.active == nil {
+		return nil
+	}
+	err := s.active.Sync()
+	if cerr := s.active.Close(); err == nil {
+		err = cerr
+	}
+	s.active = nil
+	return err
+}
+
+// ReadFrom calls fn for every record whose offset is at least offset, in order.
+// Records appended while the replay is running are not included.
+func (s *EventStore) ReadFrom(offset uint64, fn func(Record) error) error {
+	return s.read(func(r Record) bool { return r.Offset >= offset }, offset, fn)
+}
+
+// ReadSince calls fn for every record appended at or after t, in order.
+// Records appended while the replay is running are not included.
+func (s *EventStore) ReadSince(t time.Time, fn func(Record) error) error {
+	return s.read(func(r Record) bool { return !r.Time.Before(t) }, 0, fn)
+}
+
+// read replays the records that match keep, skipping the segments that end before offset.
+func (s *EventStore) read(keep func(Record) bool, offset uint64, fn func(Record) error) error {
+	s.mu.Lock()
+	segments := append([]uint64(nil), s.segments...)
+	end := s.next
+	s.mu.Unlock()
+
+	errStop := errors.New("stop")
+	for i, base := range segments {
+		if i+1 < len(segments) && segments[i+1] <= offset {
+			continue
+		}
+		_, _, err := scanSegment(s.segmentPath(base), base, func(r Record) error {
+			if r.Offset >= end {
+				return errStop
+			}
+			if !keep(r) {
+				return nil
+			}
+			return fn(r)
+		})
+		if errors.Is(err, errStop) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roll closes the active segment and starts a new one. The caller must hold s.mu.
+func (s *EventStore) roll() error {
+	if err := s.active.Sync(); err != nil {
+		s.failed = err
+		return err
+	}
+	if err := s.active.Close(); err != nil {
+		return err
+	}
+	s.unsynced = 0
+	s.segments = append(s.segments, s.next)
+	return s.openActive(s.next, 0)
+}
+
+// openActive opens the segment starting at base for appending, truncating it to size.
+func (s *EventStore) openActive(base uint64, size int64) error {
+	f, err := os.OpenFile(s.segmentPath(base), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	s.active = f
+	s.activeSize = size
+	return nil
+}
+
+// listSegments returns the base offsets of the segment files in the store directory, in order.
+func (s *EventStore) listSegments() ([]uint64, error) {
+	entries, err := os.ReadDir(s.opts.Dir)
+	if err != nil {
+		return nil, err
+	}
+	var segments []uint64
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		base, err := strconv.ParseUint(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, base)
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i] < segments[j] })
+	return segments, nil
+}
+
+// segmentPath returns the path of the segment file starting at base.
+func (s *EventStore) segmentPath(base uint64) string {
+	return filepath.Join(s.opts.Dir, fmt.Sprintf("%020d%s", base, segmentSuffix))
+}
+
+// scanSegment reads the records of the segment file at path, calling fn (if not nil) for each of them.
+// It returns the size of the valid prefix of the file and the number of valid records in it.
+func scanSegment(path string, base uint64, fn func(Record) error) (int64, uint64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var size int64
+	var count uint64
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return size, count, nil
+			}
+			return size, count, errTornRecord
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		sum := binary.BigEndian.Uint32(header[4:8])
+		// A length past the end of the file can only be garbage, and must not be allocated.
+		if int64(length) > info.Size()-size-recordHeaderSize {
+			return size, count, errTornRecord
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return size, count, errTornRecord
+		}
+		crc := crc32.Update(crc32.ChecksumIEEE(header[8:16]), crc32.IEEETable, data)
+		if crc != sum {
+			return size, count, errTornRecord
+		}
+
+		if fn != nil {
+			record := Record{
+				Offset: base + count,
+				Time:   time.Unix(0, int64(binary.BigEndian.Uint64(header[8:16]))),
+				Data:   data,
+			}
+			if err := fn(record); err != nil {
+				return size, count, err
+			}
+		}
+		size += int64(recordHeaderSize) + int64(length)
+		count++
+	}
+}
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// ConcreteSubject is a subject that notifies its observers of every state change.
+type ConcreteSubject struct {
+	observers []Observer
+	state     string
+}
+
+// Attach attaches an observer to the subject.
+func (s *ConcreteSubject) Attach(observer Observer) {
+	s.observers = append(s.observers, observer)
+}
+
+// Notify notifies all attached observers of the current state.
+func (s *ConcreteSubject) Notify() {
+	for _, o := range s.observers {
+		o.Update(s.state)
+	}
+}
+
+// GetState returns the state of the subject.
+func (s *ConcreteSubject) GetState() string {
+	return s.state
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state string) {
+	s.state = state
+}
+
+// EventStoreObserver is an Observer that appends every state change it receives to an EventStore.
+type EventStoreObserver struct {
+	store *EventStore
+}
+
+// Update appends the state to the event store.
+func (o *EventStoreObserver) Update(state string) {
+	if _, err := o.store.Append([]byte(state)); err != nil {
+		log.Printf("event store: append %q: %v", state, err)
+	}
+}
+
+// StateChangeCount is a read model that counts how many times each state was published.
+type StateChangeCount map[string]int
+
+func main() {
+	dir, err := os.MkdirTemp("", "event-store")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := EventStoreOptions{Dir: dir, MaxSegmentBytes: 64, Sync: SyncEveryN, SyncEvery: 2}
+
+	store, err := OpenEventStore(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	subject := &ConcreteSubject{}
+	subject.Attach(&EventStoreObserver{store: store})
+	for _, state := range []string{"Created", "Renamed", "Renamed", "Archived"} {
+		subject.SetState(state)
+		subject.Notify()
+	}
+
+	if err := store.Close(); err != nil {
+		log.Fatal(err)
+	}
+
+	// After a restart, the subject and the read model are rebuilt from the log.
+	store, err = OpenEventStore(opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	restored := &ConcreteSubject{}
+	counts := StateChangeCount{}
+	err = store.ReadFrom(0, func(r Record) error {
+		restored.SetState(string(r.Data))
+		counts[string(r.Data)]++
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println("Restored state:", restored.GetState())
+	fmt.Println("State changes:", counts)
+
+	err = store.ReadFrom(2, func(r Record) error {
+		fmt.Printf("Offset %d: %s\n", r.Offset, r.Data)
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+`
+In this example, the Observer interface and the ConcreteSubject struct form the application's inner layer, while the EventStore and EventStoreObserver structs belong to the infrastructure layer. The main function is the outer layer, and it publishes state changes through the subject, restarts the event store, and rebuilds both the state of a subject and a read model (StateChangeCount) by replaying the log.
+
+The EventStore struct writes every event as a record made of a header (payload length, CRC-32 and timestamp) followed by the payload. Records are appended to segment files that are named after the offset of their first record, and a new segment is started once the active one exceeds MaxSegmentBytes. The SyncPolicy type determines whether the active segment is flushed after every record, after every N records, or never. When the store is opened, every segment is validated, and a torn record at the end of the last segment (left by a crash in the middle of an append) is truncated away, while a corrupt record anywhere else is reported as ErrCorruptSegment. A record whose length goes past the end of its file is treated as torn without allocating it. If writing a record fails (for example, when the disk is full), the partly written record is truncated away before Append returns the error, so the offsets returned later are never preceded by garbage; if even the truncation fails, the store refuses every later append instead of acknowledging records that would be lost when it is reopened. A failed flush is treated in the same way: after an fsync error, the operating system gives no guarantee about which records reached the disk, so the record whose flush failed may or may not have been persisted. Retrying the append could store the event twice, so every later append fails instead, and the store must be reopened, which validates what was actually persisted.
+
+The ReadFrom and ReadSince methods replay the log from an offset or from a point in time, skipping the segments that end before the requested offset.
+
+This pattern allows the state of the system to survive restarts, and it allows new read models to be built from the full history of changes without changing the code that publishes them.
+`
@@ -0,0 +1,329 @@
+`
+The multiton pattern is a generalization of the singleton pattern that ensures that there is exactly one instance per key (for example, per tenant or per database DSN) instead of one instance overall, and provides a global access point to the instance of every key.
+`
+
+package main
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// MultitonOptions configures a Multiton.
+type MultitonOptions struct {
+	// MaxSize is the maximum number of instances. When it is exceeded, the least recently used instance is evicted.
+	// Zero means no limit.
+	MaxSize int
+	// IdleTimeout is the time after which an unused instance is evicted by EvictIdle. Zero means instances never expire.
+	IdleTimeout time.Duration
+}
+
+// multitonEntry is the instance of a key, or the build of that instance in progress.
+type multitonEntry[K comparable, V any] struct {
+	key      K
+	ready    chan struct{}
+	value    V
+	err      error
+	lastUsed time.Time
+	// refs is the number of callers of Acquire that have not released the instance yet.
+	refs int
+}
+
+// Multiton holds one instance per key, built on first use.
+// Instances that implement io.Closer are closed when they are evicted. An instance returned by GetInstance can be
+// evicted, and closed, while the caller still uses it; callers that use an instance for longer than a single call
+// should get it with Acquire, which keeps it from being evicted until it is released.
+type Multiton[K comparable, V any] struct {
+	mu      sync.Mutex
+	build   func(key K) (V, error)
+	opts    MultitonOptions
+	entries map[K]*list.Element
+	lru     *list.List
+	now     func() time.Time
+}
+
+// NewMultiton creates a new Multiton that builds the instance of a key with build.
+func NewMultiton[K comparable, V any](build func(key K) (V, error), opts MultitonOptions) *Multiton[K, V] {
+	return &Multiton[K, V]{
+		build:   build,
+		opts:    opts,
+		entries: map[K]*list.Element{},
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// GetInstance returns the instance of key, building it if needed.
+// Concurrent callers for the same key share a single build. A failed build is not kept, so the next call tries again.
+// A panic of build is treated as a failed build.
+func (m *Multiton[K, V]) GetInstance(key K) (V, error) {
+	e, err := m.get(key, false)
+	return e.value, err
+}
+
+// Acquire returns the instance of key like GetInstance, and keeps it from being evicted by MaxSize or IdleTimeout
+// until release is called. Close still closes it. release must be called exactly once, and only if err is nil.
+func (m *Multiton[K, V]) Acquire(key K) (value V, release func(), err error) {
+	e, err := m.get(key, true)
+	if err != nil {
+		return value, nil, err
+	}
+	var once sync.Once
+	return e.value, func() {
+		once.Do(func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			e.refs--
+			e.lastUsed = m.now()
+			// Keep the list ordered by last use, which EvictIdle and evictOverflow rely on.
+			if el, ok := m.entries[e.key]; ok && el.Value == e {
+				m.lru.MoveToFront(el)
+			}
+		})
+	}, nil
+}
+
+// get returns the entry of key once it has been built, building it if needed. If acquire is true, the entry is
+// referenced, unless its build failed.
+func (m *Multiton[K, V]) get(key K, acquire bool) (*multitonEntry[K, V], error) {
+	m.mu.Lock()
+	if el, ok := m.entries[key]; ok {
+		e := el.Value.(*multitonEntry[K, V])
+		e.lastUsed = m.now()
+		m.lru.MoveToFront(el)
+		if acquire {
+			e.refs++
+		}
+		m.mu.Unlock()
+		<-e.ready
+		if e.err != nil && acquire {
+			m.mu.Lock()
+			e.refs--
+			m.mu.Unlock()
+		}
+		return e, e.err
+	}
+
+	e := &multitonEntry[K, V]{key: key, ready: make(chan struct{}), lastUsed: m.now()}
+	if acquire {
+		e.refs++
+	}
+	m.entries[key] = m.lru.PushFront(e)
+	evicted := m.evictOverflow()
+	m.mu.Unlock()
+	closeAll(evicted)
+
+	defer close(e.ready)
+	e.value, e.err = m.buildInstance(key)
+
+	m.mu.Lock()
+	if e.err != nil {
+		if acquire {
+			e.refs--
+		}
+		if el, ok := m.entries[key]; ok && el.Value == e {
+			m.lru.Remove(el)
+			delete(m.entries, key)
+		}
+	}
+	m.mu.Unlock()
+
+	return e, e.err
+}
+
+// buildInstance builds the instance of key, returning a panic of build as an error.
+func (m *Multiton[K, V]) buildInstance(key K) (value V, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("build %v: panic: %v", key, r)
+		}
+	}()
+	return m.build(key)
+}
+
+// EvictIdle evicts the instances that have not been used for IdleTimeout and returns how many were evicted.
+func (m *Multiton[K, V]) EvictIdle() int {
+	if m.opts.IdleTimeout <= 0 {
+		return 0
+	}
+
+	m.mu.Lock()
+	cutoff := m.now().Add(-m.opts.IdleTimeout)
+	var evicted []any
+	for el := m.lru.Back(); el != nil; {
+		prev := el.Prev()
+		e := el.Value.(*multitonEntry[K, V])
+		if !e.lastUsed.Before(cutoff) {
+			break
+		}
+		if v, ok := m.remove(el); ok {
+			evicted = append(evicted, v)
+		}
+		el = prev
+	}
+	m.mu.Unlock()
+
+	closeAll(evicted)
+	return len(evicted)
+}
+
+// Run evicts the idle instances every interval until ctx is cancelled.
+func (m *Multiton[K, V]) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.EvictIdle()
+		}
+	}
+}
+
+// Len returns the number of instances.
+func (m *Multiton[K, V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lru.Len()
+}
+
+// Close evicts every instance, including the acquired ones, returning the errors of the instances that failed to
+// close. It must only be called once the instances are no longer used.
+func (m *Multiton[K, V]) Close() error {
+	m.mu.Lock()
+	var evicted []any
+	for el := m.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if v, ok := m.removeEntry(el, true); ok {
+			evicted = append(evicted, v)
+		}
+		el = prev
+	}
+	m.mu.Unlock()
+	return closeAll(evicted)
+}
+
+// evictOverflow evicts the least recently used instances beyond MaxSize. Acquired instances are not evicted, so the
+// multiton can hold more than MaxSize instances while they are in use. The caller must hold m.mu.
+func (m *Multiton[K, V]) evictOverflow() []any {
+	if m.opts.MaxSize <= 0 {
+		return nil
+	}
+	var evicted []any
+	for el := m.lru.Back(); el != nil && m.lru.Len() > m.opts.MaxSize; {
+		prev := el.Prev()
+		if v, ok := m.remove(el); ok {
+			evicted = append(evicted, v)
+		}
+		el = prev
+	}
+	return evicted
+}
+
+// remove removes the instance of el if it has been built and is not acquired, returning it. Instances still being
+// built or acquired are left in place. The caller must hold m.mu.
+func (m *Multiton[K, V]) remove(el *list.Element) (any, bool) {
+	return m.removeEntry(el, false)
+}
+
+// removeEntry removes the instance of el like remove, including an acquired instance if force is true.
+// The caller must hold m.mu.
+func (m *Multiton[K, V]) removeEntry(el *list.Element, force bool) (any, bool) {
+	e := el.Value.(*multitonEntry[K, V])
+	select {
+	case <-e.ready:
+	default:
+		return nil, false
+	}
+	if e.refs > 0 && !force {
+		return nil, false
+	}
+	m.lru.Remove(el)
+	delete(m.entries, e.key)
+	return e.value, e.err == nil
+}
+
+// closeAll closes the instances that implement io.Closer.
+func closeAll(instances []any) error {
+	var errs []error
+	for _, v := range instances {
+		if c, ok := v.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Connection is a database connection for a tenant.
+type Connection struct {
+	tenant string
+}
+
+// Close closes the connection.
+func (c *Connection) Close() error {
+	fmt.Println("Closing connection of", c.tenant)
+	return nil
+}
+
+func main() {
+	builds := 0
+	connections := NewMultiton(func(tenant string) (*Connection, error) {
+		builds++
+		return &Connection{tenant: tenant}, nil
+	}, MultitonOptions{MaxSize: 2, IdleTimeout: time.Minute})
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	connections.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	results := make([]*Connection, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = connections.GetInstance("acme")
+		}()
+	}
+	wg.Wait()
+	fmt.Println("Connections built for acme:", builds)
+
+	now = now.Add(30 * time.Second)
+	connections.GetInstance("globex")
+	now = now.Add(30 * time.Second)
+	_, release, err := connections.Acquire("initech")
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	now = now.Add(40 * time.Second)
+	fmt.Println("Idle connections evicted:", connections.EvictIdle())
+
+	now = now.Add(2 * time.Minute)
+	fmt.Println("Idle connections evicted while initech is in use:", connections.EvictIdle())
+	release()
+	now = now.Add(2 * time.Minute)
+	fmt.Println("Idle connections evicted after release:", connections.EvictIdle())
+
+	connections.Close()
+}
+
+`
+In this example, the Connection struct is the inner layer, and the Multiton struct is part of the infrastructure layer. The main function is the outer layer, and it gets the connection of the same tenant from ten goroutines at once, then the connections of two more tenants, and evicts the idle connections.
+
+The GetInstance method returns the instance of a key, and builds it on first use. The first caller for a key records a pending entry before building the instance, so concurrent callers for the same key wait for that build instead of starting their own, and the instance is built at most once per key. A failed build is removed, so the next caller tries again.
+
+The Multiton struct keeps its instances in a least recently used list. When building a new instance exceeds MaxSize, the least recently used instances are evicted (the connection of "acme" in the example), and EvictIdle evicts the instances that have not been used for IdleTimeout (the connection of "globex"). Evicted instances that implement io.Closer are closed, outside the lock of the multiton.
+
+An instance returned by GetInstance can be evicted and closed while its caller is still using it, such as a connection pool closed under a query in progress. A caller that uses an instance for longer than a single call gets it with Acquire instead, which keeps the instance from being evicted until the release function is called (the connection of "initech" in the example). Acquired instances can make the multiton hold more than MaxSize instances for a while, and Close closes them anyway, so it must only be called once the instances are no longer used. A build that panics is treated as a failed build, so the callers waiting for it are released and the next caller tries again.
+
+This pattern allows the instances of a potentially unbounded set of keys to be shared without leaking resources, as instances that are no longer used are closed.
+`
@@ -0,0 +1,203 @@
+`
+Batched notifications are an extension of the observer pattern that coalesces several state changes into a single notification. Notifications requested while a transaction is open are held back, the observers are notified once when the transaction is committed, and they are not notified at all when it is rolled back.
+`
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// ConcreteObserver is a concrete implementation of Observer.
+type ConcreteObserver struct{}
+
+// Update updates the observer based on the state of the subject.
+func (o *ConcreteObserver) Update(state string) {
+	fmt.Println("ConcreteObserver:", state)
+}
+
+// ConcreteSubject is a subject whose notifications can be batched with a transaction.
+type ConcreteSubject struct {
+	mu        sync.Mutex
+	observers []Observer
+	state     string
+	batch     *Transaction
+}
+
+// Attach attaches an observer to the subject.
+func (s *ConcreteSubject) Attach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+// Detach detaches an observer from the subject.
+func (s *ConcreteSubject) Detach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.observers {
+		if o == observer {
+			s.observers = append(s.observers[:i], s.observers[i+1:]...)
+			break
+		}
+	}
+}
+
+// GetState returns the state of the subject.
+func (s *ConcreteSubject) GetState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Notify notifies all attached observers of the state of the subject.
+// While a transaction is open, the notification is deferred until the transaction is committed.
+func (s *ConcreteSubject) Notify() {
+	s.mu.Lock()
+	if s.batch != nil {
+		s.batch.pending = true
+		s.mu.Unlock()
+		return
+	}
+	state := s.state
+	observers := append([]Observer(nil), s.observers...)
+	s.mu.Unlock()
+
+	for _, o := range observers {
+		o.Update(state)
+	}
+}
+
+// ErrTransactionOpen is returned when a transaction is started on a subject that already has one open.
+var ErrTransactionOpen = errors.New("subject already has an open transaction")
+
+// Begin starts a transaction on the subject. Every state change made to the subject until the transaction ends,
+// by any caller, is part of the transaction.
+func (s *ConcreteSubject) Begin() (*Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.batch != nil {
+		return nil, ErrTransactionOpen
+	}
+	s.batch = &Transaction{subject: s, state: s.state}
+	return s.batch, nil
+}
+
+// Batch runs fn in a transaction. The transaction is committed if fn returns nil,
+// and rolled back if fn returns an error or panics.
+func (s *ConcreteSubject) Batch(fn func() error) error {
+	tx, err := s.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// ErrTransactionDone is returned when a transaction is used after it has been committed or rolled back.
+var ErrTransactionDone = errors.New("transaction has already been committed or rolled back")
+
+// Transaction holds back the notifications of a subject until it is committed.
+type Transaction struct {
+	subject *ConcreteSubject
+	// state is the state of the subject when the transaction started, restored by Rollback.
+	state   string
+	pending bool
+	done    bool
+}
+
+// Commit ends the transaction and notifies the observers once with the final state, if Notify was called during
+// the transaction.
+func (tx *Transaction) Commit() error {
+	s := tx.subject
+	s.mu.Lock()
+	if tx.done {
+		s.mu.Unlock()
+		return ErrTransactionDone
+	}
+	tx.done = true
+	s.batch = nil
+	pending := tx.pending
+	s.mu.Unlock()
+
+	if pending {
+		s.Notify()
+	}
+	return nil
+}
+
+// Rollback ends the transaction, restores the state the subject had when it started and discards the deferred
+// notifications. Rolling back a finished transaction does nothing.
+func (tx *Transaction) Rollback() {
+	s := tx.subject
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if tx.done {
+		return
+	}
+	tx.done = true
+	s.batch = nil
+	s.state = tx.state
+}
+
+func main() {
+	subject := &ConcreteSubject{}
+	subject.Attach(&ConcreteObserver{})
+
+	subject.SetState("Hello")
+	subject.Notify()
+
+	err := subject.Batch(func() error {
+		subject.SetState("Dear")
+		subject.Notify()
+		subject.SetState(subject.GetState() + " World")
+		subject.Notify()
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	err = subject.Batch(func() error {
+		subject.SetState("Goodbye")
+		subject.Notify()
+		return errors.New("validation failed")
+	})
+	if err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	fmt.Println("State:", subject.GetState())
+}
+
+`
+In this example, the Observer interface and the ConcreteObserver struct form the application's inner layer, while the ConcreteSubject and Transaction structs are part of the infrastructure layer. The main function is the outer layer, and it changes the state of the subject once directly and twice inside transactions.
+
+As in the other observer examples, the SetState method of the ConcreteSubject struct changes the state and the Notify method notifies the observers. The Begin method opens a Transaction on the subject itself, so every state change made to the subject while it is open is part of it, whoever makes it, and none is lost. While the transaction is open, Notify only records that the observers must be notified. When the transaction is committed, the observers are notified once with the final state, if Notify was called at all; when it is rolled back, the state the subject had when the transaction started is restored and the observers are not notified. A subject has at most one open transaction, and Begin fails with ErrTransactionOpen while there is one. The Batch method runs a function in a transaction and commits or rolls it back depending on whether the function succeeds.
+
+This pattern allows several related state changes to be published as one, so observers never see (or react to) the intermediate states.
+`
@@ -0,0 +1,354 @@
+`
+Introspection and metrics make the observer pattern observable itself. The subject can list its subscriptions (who is subscribed, with which priority, and how many notifications are waiting for them), and it counts the notifications sent, dropped and failed and measures how long deliveries take, in a format that a monitoring system such as Prometheus can scrape.
+`
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// ConcreteObserver is a concrete implementation of Observer that takes delay to handle every update.
+type ConcreteObserver struct {
+	delay time.Duration
+}
+
+// Update updates the observer based on the state of the subject.
+func (o *ConcreteObserver) Update(state string) {
+	time.Sleep(o.delay)
+}
+
+// FaultyObserver is an Observer that panics on every update.
+type FaultyObserver struct{}
+
+// Update panics.
+func (o *FaultyObserver) Update(state string) {
+	panic("cannot handle " + state)
+}
+
+// SubscriptionOptions configures a subscription.
+type SubscriptionOptions struct {
+	// Name identifies the subscription in introspection and metrics, and must be unique within the subject.
+	// It defaults to the type of the observer followed by the number of the subscription, such as "*main.Logger#2".
+	Name string
+	// Priority orders the subscriptions: notifications are queued for higher priorities first.
+	Priority int
+	// QueueSize is the number of notifications that can wait for the observer. Notifications beyond it are dropped.
+	QueueSize int
+}
+
+// SubscriptionInfo describes a subscription.
+type SubscriptionInfo struct {
+	Name       string
+	Observer   string
+	Priority   int
+	QueueDepth int
+	QueueSize  int
+}
+
+// notification is a state waiting to be delivered, with the time it was queued.
+type notification struct {
+	state    string
+	queuedAt time.Time
+}
+
+// subscription is an observer attached to a ConcreteSubject, with its own queue and delivery goroutine.
+type subscription struct {
+	observer Observer
+	opts     SubscriptionOptions
+	queue    chan notification
+	done     chan struct{}
+	metrics  *subscriptionMetrics
+}
+
+// subscriptionMetrics are the counters and the latency histogram of a subscription.
+type subscriptionMetrics struct {
+	sent    uint64
+	dropped uint64
+	failed  uint64
+	latency *Histogram
+}
+
+// Histogram counts observations in cumulative buckets.
+type Histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram creates a new Histogram with the given upper bounds, in increasing order.
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(v float64) {
+	for i, bound := range h.bounds {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the delivery latency histogram.
+var DefaultLatencyBuckets = []float64{0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// ConcreteSubject is a subject that delivers its notifications asynchronously and keeps metrics about them.
+type ConcreteSubject struct {
+	mu            sync.Mutex
+	name          string
+	subscriptions []*subscription
+	state         string
+	attached      int
+	wg            sync.WaitGroup
+	now           func() time.Time
+}
+
+// NewConcreteSubject creates a new ConcreteSubject identified by name in the metrics.
+func NewConcreteSubject(name string) *ConcreteSubject {
+	return &ConcreteSubject{name: name, now: time.Now}
+}
+
+// Attach attaches an observer to the subject and starts delivering notifications to it.
+// It fails if another subscription of the subject has the same name, as their metrics could not be told apart.
+func (s *ConcreteSubject) Attach(observer Observer, opts SubscriptionOptions) error {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 16
+	}
+	sub := &subscription{
+		observer: observer,
+		opts:     opts,
+		queue:    make(chan notification, opts.QueueSize),
+		done:     make(chan struct{}),
+		metrics:  &subscriptionMetrics{latency: NewHistogram(DefaultLatencyBuckets)},
+	}
+
+	s.mu.Lock()
+	s.attached++
+	if sub.opts.Name == "" {
+		sub.opts.Name = fmt.Sprintf("%T#%d", observer, s.attached)
+	}
+	for _, other := range s.subscriptions {
+		if other.opts.Name == sub.opts.Name {
+			s.mu.Unlock()
+			return fmt.Errorf("attach %T: subscription name %q is already used", observer, sub.opts.Name)
+		}
+	}
+	s.subscriptions = append(s.subscriptions, sub)
+	sort.SliceStable(s.subscriptions, func(i, j int) bool {
+		return s.subscriptions[i].opts.Priority > s.subscriptions[j].opts.Priority
+	})
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go s.deliver(sub)
+	return nil
+}
+
+// Detach detaches an observer from the subject. The notifications waiting for it are discarded.
+func (s *ConcreteSubject) Detach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sub := range s.subscriptions {
+		if sub.observer == observer {
+			close(sub.done)
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			break
+		}
+	}
+}
+
+// Close detaches every observer and waits for the deliveries in progress to finish.
+func (s *ConcreteSubject) Close() {
+	s.mu.Lock()
+	for _, sub := range s.subscriptions {
+		close(sub.done)
+	}
+	s.subscriptions = nil
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Notify queues the current state for every attached observer, in order of priority.
+// The notification is dropped for the observers whose queue is full.
+func (s *ConcreteSubject) Notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := notification{state: s.state, queuedAt: s.now()}
+	for _, sub := range s.subscriptions {
+		select {
+		case sub.queue <- n:
+		default:
+			sub.metrics.dropped++
+		}
+	}
+}
+
+// deliver passes the queued notifications to the observer of sub until it is detached.
+func (s *ConcreteSubject) deliver(sub *subscription) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-sub.done:
+			return
+		case n := <-sub.queue:
+			ok := update(sub.observer, n.state)
+			latency := s.now().Sub(n.queuedAt).Seconds()
+
+			s.mu.Lock()
+			if ok {
+				sub.metrics.sent++
+			} else {
+				sub.metrics.failed++
+			}
+			sub.metrics.latency.Observe(latency)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// update calls the observer with state, reporting whether it returned without panicking.
+func update(observer Observer, state string) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	observer.Update(state)
+	return true
+}
+
+// GetState returns the state of the subject.
+func (s *ConcreteSubject) GetState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Subscriptions lists the subscriptions of the subject, in order of priority.
+func (s *ConcreteSubject) Subscriptions() []SubscriptionInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infos := make([]SubscriptionInfo, len(s.subscriptions))
+	for i, sub := range s.subscriptions {
+		infos[i] = SubscriptionInfo{
+			Name:       sub.opts.Name,
+			Observer:   fmt.Sprintf("%T", sub.observer),
+			Priority:   sub.opts.Priority,
+			QueueDepth: len(sub.queue),
+			QueueSize:  sub.opts.QueueSize,
+		}
+	}
+	return infos
+}
+
+// WriteMetrics writes the metrics of the subject's subscriptions to w in the Prometheus text exposition format.
+func (s *ConcreteSubject) WriteMetrics(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+	counter := func(name, help string, value func(m *subscriptionMetrics) uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, sub := range s.subscriptions {
+			fmt.Fprintf(&b, "%s{%s} %d\n", name, s.labels(sub), value(sub.metrics))
+		}
+	}
+	counter("observer_notifications_sent_total", "Notifications delivered to observers.", func(m *subscriptionMetrics) uint64 { return m.sent })
+	counter("observer_notifications_dropped_total", "Notifications dropped because the observer queue was full.", func(m *subscriptionMetrics) uint64 { return m.dropped })
+	counter("observer_notifications_failed_total", "Notifications whose delivery panicked.", func(m *subscriptionMetrics) uint64 { return m.failed })
+
+	b.WriteString("# HELP observer_queue_depth Notifications waiting to be delivered.\n# TYPE observer_queue_depth gauge\n")
+	for _, sub := range s.subscriptions {
+		fmt.Fprintf(&b, "observer_queue_depth{%s} %d\n", s.labels(sub), len(sub.queue))
+	}
+
+	name := "observer_delivery_latency_seconds"
+	fmt.Fprintf(&b, "# HELP %s Time from notification to the end of delivery.\n# TYPE %s histogram\n", name, name)
+	for _, sub := range s.subscriptions {
+		h := sub.metrics.latency
+		for i, bound := range h.bounds {
+			fmt.Fprintf(&b, "%s_bucket{%s,le=\"%g\"} %d\n", name, s.labels(sub), bound, h.counts[i])
+		}
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, s.labels(sub), h.count)
+		fmt.Fprintf(&b, "%s_sum{%s} %g\n", name, s.labels(sub), h.sum)
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", name, s.labels(sub), h.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// labels returns the Prometheus labels identifying a subscription.
+func (s *ConcreteSubject) labels(sub *subscription) string {
+	return fmt.Sprintf("subject=%q,subscription=%q,priority=\"%d\"", s.name, sub.opts.Name, sub.opts.Priority)
+}
+
+// ServeHTTP serves the metrics of the subject, so the subject can be registered as a /metrics handler.
+func (s *ConcreteSubject) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.WriteMetrics(w)
+}
+
+func main() {
+	subject := NewConcreteSubject("greeting")
+	subscriptions := []struct {
+		observer Observer
+		opts     SubscriptionOptions
+	}{
+		{&ConcreteObserver{}, SubscriptionOptions{Name: "cache", Priority: 10}},
+		{&ConcreteObserver{delay: 20 * time.Millisecond}, SubscriptionOptions{Name: "ui", QueueSize: 2}},
+		{&FaultyObserver{}, SubscriptionOptions{Name: "audit"}},
+	}
+	for _, sub := range subscriptions {
+		if err := subject.Attach(sub.observer, sub.opts); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+
+	for _, state := range []string{"Hello", "Dear", "World", "Again"} {
+		subject.SetState(state)
+		subject.Notify()
+	}
+
+	for _, info := range subject.Subscriptions() {
+		fmt.Printf("%s (%s): priority %d, queue %d/%d\n", info.Name, info.Observer, info.Priority, info.QueueDepth, info.QueueSize)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	subject.WriteMetrics(os.Stdout)
+	subject.Close()
+}
+
+`
+In this example, the Observer interface and the ConcreteObserver and FaultyObserver structs form the application's inner layer, while the ConcreteSubject struct is part of the infrastructure layer. The main function is the outer layer, and it attaches three observers with different priorities and queue sizes, notifies them of four state changes, and prints the subscriptions and the metrics of the subject.
+
+The ConcreteSubject struct gives every subscription its own queue and delivery goroutine. Notify queues the state for the subscriptions in order of priority, and counts a dropped notification when a queue is full, as happens to the slow "ui" observer. The delivery goroutine calls the observer, counts the notification as sent or (if the observer panicked) as failed, and records the time from Notify to the end of the delivery in a histogram.
+
+The Subscriptions method lists the name, observer type, priority and queue depth of every subscription, and the WriteMetrics method writes the counters, queue depths and latency histograms in the Prometheus text exposition format, labelled with the subject and subscription names. Since a metric must not have two series with the same labels, the names of the subscriptions of a subject are unique: Attach rejects a name that is already used, and an unnamed subscription is named after the type of its observer and its number, so two observers of the same type do not collide. The ConcreteSubject struct also implements http.Handler, so it can be exposed to Prometheus with http.Handle("/metrics", subject).
+
+This pattern allows slow, failing or overloaded observers to be found and monitored in production, without changing the observers themselves.
+`
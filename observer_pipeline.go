@@ -0,0 +1,254 @@
+`
+Bridges connect the observer pattern to the pipe and filter architecture. A subject source is an observer that feeds the state changes of a subject into a pipe, so they can be processed by filters, and a subject sink reads the output of a pipe and publishes every value to the observers of a subject.
+`
+
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state interface{})
+}
+
+// ConcreteObserver is a concrete implementation of Observer.
+type ConcreteObserver struct {
+	name string
+}
+
+// Update updates the observer based on the state of the subject.
+func (o *ConcreteObserver) Update(state interface{}) {
+	fmt.Printf("%s: %v\n", o.name, state)
+}
+
+// ConcreteSubject is a subject that notifies its observers of every state change.
+type ConcreteSubject struct {
+	mu        sync.Mutex
+	observers []Observer
+	state     interface{}
+}
+
+// Attach attaches an observer to the subject.
+func (s *ConcreteSubject) Attach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+// Detach detaches an observer from the subject.
+func (s *ConcreteSubject) Detach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.observers {
+		if o == observer {
+			s.observers = append(s.observers[:i], s.observers[i+1:]...)
+			break
+		}
+	}
+}
+
+// Notify notifies all attached observers of the current state.
+func (s *ConcreteSubject) Notify() {
+	s.mu.Lock()
+	state := s.state
+	observers := append([]Observer(nil), s.observers...)
+	s.mu.Unlock()
+
+	for _, o := range observers {
+		o.Update(state)
+	}
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Filter is an interface for filters.
+type Filter interface {
+	Process(data chan interface{}, output chan interface{})
+}
+
+// ConcreteFilterA is a concrete implementation of Filter.
+type ConcreteFilterA struct{}
+
+// Process processes the data and sends the result to the output channel.
+func (f *ConcreteFilterA) Process(data chan interface{}, output chan interface{}) {
+	for d := range data {
+		output <- d.(int) * 2
+	}
+	close(output)
+}
+
+// BufferPolicy determines what a SubjectSource does when its pipe is full.
+type BufferPolicy int
+
+const (
+	// Block blocks the notifying subject until the pipe has room.
+	Block BufferPolicy = iota
+	// DropNewest discards the new state change.
+	DropNewest
+	// DropOldest discards the oldest state change waiting in the pipe.
+	DropOldest
+)
+
+// SubjectSource is an Observer that feeds the state changes of a subject into a pipe.
+type SubjectSource struct {
+	mu        sync.Mutex
+	subject   *ConcreteSubject
+	policy    BufferPolicy
+	output    chan interface{}
+	done      chan struct{}
+	closeOnce sync.Once
+	closed    bool
+	dropped   int
+}
+
+// NewSubjectSource creates a new SubjectSource attached to subject, with a pipe that buffers size state changes.
+// The drop policies need a buffer of at least one state change, as an unbuffered pipe is never full and never has
+// a state change to drop either.
+func NewSubjectSource(subject *ConcreteSubject, size int, policy BufferPolicy) (*SubjectSource, error) {
+	if size < 0 || (size < 1 && policy != Block) {
+		return nil, fmt.Errorf("subject source: invalid buffer size %d for policy %d", size, policy)
+	}
+	s := &SubjectSource{
+		subject: subject,
+		policy:  policy,
+		output:  make(chan interface{}, size),
+		done:    make(chan struct{}),
+	}
+	subject.Attach(s)
+	return s, nil
+}
+
+// Output returns the pipe the state changes are sent to.
+func (s *SubjectSource) Output() chan interface{} {
+	return s.output
+}
+
+// Update sends the state to the pipe, applying the buffering policy if the pipe is full.
+func (s *SubjectSource) Update(state interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+
+	switch s.policy {
+	case Block:
+		select {
+		case s.output <- state:
+		case <-s.done:
+		}
+	case DropNewest:
+		select {
+		case s.output <- state:
+		default:
+			s.dropped++
+		}
+	case DropOldest:
+		for {
+			select {
+			case s.output <- state:
+				return
+			default:
+			}
+			select {
+			case <-s.output:
+				s.dropped++
+			default:
+			}
+		}
+	}
+}
+
+// Dropped returns the number of state changes discarded by the buffering policy.
+func (s *SubjectSource) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close detaches the source from its subject and closes the pipe, which ends the pipeline.
+// A state change blocked on a full pipe is discarded.
+func (s *SubjectSource) Close() {
+	s.subject.Detach(s)
+	s.closeOnce.Do(func() {
+		close(s.done)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.closed = true
+		close(s.output)
+	})
+}
+
+// SubjectSink publishes the values read from a pipe to the observers of a subject.
+type SubjectSink struct {
+	subject *ConcreteSubject
+}
+
+// NewSubjectSink creates a new SubjectSink that publishes to subject.
+func NewSubjectSink(subject *ConcreteSubject) *SubjectSink {
+	return &SubjectSink{subject: subject}
+}
+
+// Process sets every value read from data as the state of the subject and notifies its observers.
+// It returns once data is closed.
+func (s *SubjectSink) Process(data chan interface{}) {
+	for d := range data {
+		s.subject.SetState(d)
+		s.subject.Notify()
+	}
+}
+
+func main() {
+	input := &ConcreteSubject{}
+	output := &ConcreteSubject{}
+	output.Attach(&ConcreteObserver{name: "ConcreteObserver"})
+
+	source, err := NewSubjectSource(input, 4, Block)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	sink := NewSubjectSink(output)
+	doubled := make(chan interface{})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		filterA := &ConcreteFilterA{}
+		filterA.Process(source.Output(), doubled)
+	}()
+
+	go func() {
+		defer wg.Done()
+		sink.Process(doubled)
+	}()
+
+	for i := 0; i < 5; i++ {
+		input.SetState(i)
+		input.Notify()
+	}
+	source.Close()
+
+	wg.Wait()
+}
+
+`
+In this example, the Observer interface and the ConcreteSubject struct come from the observer pattern, and the Filter interface and the ConcreteFilterA struct come from the pipe and filter architecture. The SubjectSource and SubjectSink structs are the bridges between them. The main function is the outer layer, and it feeds the state changes of one subject through a filter and publishes the result to the observers of another subject.
+
+The SubjectSource struct is an observer that sends every state change of its subject to a buffered pipe. When the pipe is full, the BufferPolicy type determines whether the subject blocks until the filter catches up (Block), the new state change is discarded (DropNewest), or the oldest waiting state change is discarded (DropOldest); discarded state changes are counted by Dropped. The drop policies need a buffer of at least one state change, so NewSubjectSource rejects a smaller size for them. Closing the source detaches it from the subject and closes the pipe, which ends the pipeline in the same way as the data generator of the pipe and filter example.
+
+The SubjectSink struct reads the output of the last filter, sets every value as the state of its subject and notifies the observers of the subject.
+
+This pattern allows events produced by subjects to be processed by reusable filters, and the results of a pipeline to be consumed by observers, without either side knowing about the other.
+`
@@ -0,0 +1,232 @@
+`
+Failure isolation is an extension of the observer pattern that protects the subject and the other observers from a failing observer. Every call to an observer is isolated with recover, failures are reported to an error handler, failed calls can be retried with a backoff, and an observer that keeps failing is detached automatically.
+`
+
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// ConcreteObserver is a concrete implementation of Observer.
+type ConcreteObserver struct {
+	name string
+}
+
+// Update updates the observer based on the state of the subject.
+func (o *ConcreteObserver) Update(state string) {
+	fmt.Printf("%s: %s\n", o.name, state)
+}
+
+// String returns the name of the observer.
+func (o *ConcreteObserver) String() string {
+	return o.name
+}
+
+// FaultyObserver is an Observer that panics on every update.
+type FaultyObserver struct{}
+
+// Update panics.
+func (o *FaultyObserver) Update(state string) {
+	panic("cannot handle " + state)
+}
+
+// ObserverError describes a failed call to an observer.
+type ObserverError struct {
+	// Observer is the observer that failed.
+	Observer Observer
+	// State is the state the observer was notified of.
+	State string
+	// Attempt is the number of the failed attempt, starting at 1.
+	Attempt int
+	// Panic is the value the observer panicked with.
+	Panic any
+	// Stack is the stack trace of the panic.
+	Stack []byte
+	// Detached reports whether the observer was detached because of this failure.
+	Detached bool
+}
+
+// Error returns a description of the failure.
+func (e *ObserverError) Error() string {
+	return fmt.Sprintf("observer %s: update %q (attempt %d): panic: %v", ObserverName(e.Observer), e.State, e.Attempt, e.Panic)
+}
+
+// ObserverName returns the identity of an observer: its String method if it has one, or its type and address otherwise.
+func ObserverName(observer Observer) string {
+	if s, ok := observer.(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprintf("%T(%p)", observer, observer)
+}
+
+// SubjectOptions configures how a ConcreteSubject handles failing observers.
+type SubjectOptions struct {
+	// ErrorHandler is called for every failed call to an observer. It defaults to printing the error.
+	ErrorHandler func(err *ObserverError)
+	// Retries is the number of times a failed call is retried.
+	Retries int
+	// Backoff is the delay before the first retry. It doubles after every retry.
+	Backoff time.Duration
+	// MaxConsecutiveFailures is the number of consecutive failed notifications after which an observer is detached.
+	// Zero means observers are never detached.
+	MaxConsecutiveFailures int
+}
+
+// subscription is an observer attached to a ConcreteSubject, with its count of consecutive failures.
+type subscription struct {
+	observer Observer
+	failures int
+}
+
+// ConcreteSubject is a concrete implementation of Subject that isolates its observers from each other.
+type ConcreteSubject struct {
+	mu            sync.Mutex
+	opts          SubjectOptions
+	sleep         func(time.Duration)
+	subscriptions []*subscription
+	state         string
+}
+
+// NewConcreteSubject creates a new ConcreteSubject with the given options.
+func NewConcreteSubject(opts SubjectOptions) *ConcreteSubject {
+	if opts.ErrorHandler == nil {
+		opts.ErrorHandler = func(err *ObserverError) { fmt.Println("Error:", err) }
+	}
+	return &ConcreteSubject{opts: opts, sleep: time.Sleep}
+}
+
+// Attach attaches an observer to the subject.
+func (s *ConcreteSubject) Attach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscriptions = append(s.subscriptions, &subscription{observer: observer})
+}
+
+// Detach detaches an observer from the subject.
+func (s *ConcreteSubject) Detach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, sub := range s.subscriptions {
+		if sub.observer == observer {
+			s.subscriptions = append(s.subscriptions[:i], s.subscriptions[i+1:]...)
+			break
+		}
+	}
+}
+
+// Notify notifies all attached observers of the current state.
+// A failing observer does not prevent the remaining observers from being notified.
+func (s *ConcreteSubject) Notify() {
+	s.mu.Lock()
+	state := s.state
+	subscriptions := append([]*subscription(nil), s.subscriptions...)
+	s.mu.Unlock()
+
+	for _, sub := range subscriptions {
+		s.deliver(sub, state)
+	}
+}
+
+// deliver calls the observer of sub with state, retrying and reporting failures.
+func (s *ConcreteSubject) deliver(sub *subscription, state string) {
+	backoff := s.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		err := update(sub.observer, state, attempt)
+		if err == nil {
+			s.mu.Lock()
+			sub.failures = 0
+			s.mu.Unlock()
+			return
+		}
+
+		last := attempt > s.opts.Retries
+		if last {
+			s.mu.Lock()
+			sub.failures++
+			if s.opts.MaxConsecutiveFailures > 0 && sub.failures >= s.opts.MaxConsecutiveFailures {
+				err.Detached = true
+			}
+			s.mu.Unlock()
+			if err.Detached {
+				s.Detach(sub.observer)
+			}
+		}
+		s.opts.ErrorHandler(err)
+		if last {
+			return
+		}
+
+		s.sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// update calls the observer with state, converting a panic into an ObserverError.
+func update(observer Observer, state string, attempt int) (err *ObserverError) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &ObserverError{
+				Observer: observer,
+				State:    state,
+				Attempt:  attempt,
+				Panic:    r,
+				Stack:    debug.Stack(),
+			}
+		}
+	}()
+	observer.Update(state)
+	return nil
+}
+
+// GetState returns the state of the subject.
+func (s *ConcreteSubject) GetState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+func main() {
+	subject := NewConcreteSubject(SubjectOptions{
+		Retries:                1,
+		Backoff:                10 * time.Millisecond,
+		MaxConsecutiveFailures: 2,
+		ErrorHandler: func(err *ObserverError) {
+			fmt.Println("Error:", err)
+			if err.Detached {
+				fmt.Println("Detached:", ObserverName(err.Observer))
+			}
+		},
+	})
+
+	subject.Attach(&FaultyObserver{})
+	subject.Attach(&ConcreteObserver{name: "ConcreteObserver"})
+
+	for _, state := range []string{"Hello", "Dear", "World"} {
+		subject.SetState(state)
+		subject.Notify()
+	}
+}
+
+`
+In this example, the Observer interface and the ConcreteObserver and FaultyObserver structs form the application's inner layer, while the ConcreteSubject struct is part of the infrastructure layer. The main function is the outer layer, and it notifies a faulty observer and a well-behaved observer of three state changes.
+
+The ConcreteSubject struct calls every observer through the update function, which recovers from a panic and converts it into an ObserverError that carries the identity of the observer, the state it was notified of, the attempt number and the stack trace. A failed call is retried up to Retries times, waiting Backoff before the first retry and doubling the delay after each one, and every failed attempt is reported to the ErrorHandler. After MaxConsecutiveFailures notifications in a row have failed, the observer is detached, so the faulty observer is no longer notified of "World". Notify works on a copy of the list of observers, so observers can be detached while a notification is in progress.
+
+This pattern allows one misbehaving observer to fail without crashing the caller of Notify and without preventing the other observers from being notified.
+`
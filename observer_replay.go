@@ -0,0 +1,207 @@
+`
+Behavior and replay subjects are variants of the observer pattern for late subscribers. A behavior subject immediately sends its current state to every new observer, and a replay subject replays the last N notifications (or the notifications from the last duration) before delivering live updates.
+`
+
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// ConcreteObserver is a concrete implementation of Observer that prints every update it receives.
+type ConcreteObserver struct {
+	name string
+}
+
+// Update updates the observer with a state of the subject.
+func (o *ConcreteObserver) Update(state string) {
+	fmt.Printf("%s: %s\n", o.name, state)
+}
+
+// Subject is an interface for subjects.
+type Subject interface {
+	Attach(observer Observer)
+	Detach(observer Observer)
+	Notify()
+	GetState() string
+	SetState(state string)
+}
+
+// ConcreteSubject is a concrete implementation of Subject.
+// Observers attached after a notification do not see it.
+type ConcreteSubject struct {
+	mu        sync.Mutex
+	observers []Observer
+	state     string
+}
+
+// Attach attaches an observer to the subject.
+func (s *ConcreteSubject) Attach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+}
+
+// Detach detaches an observer from the subject.
+func (s *ConcreteSubject) Detach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, o := range s.observers {
+		if o == observer {
+			s.observers = append(s.observers[:i], s.observers[i+1:]...)
+			break
+		}
+	}
+}
+
+// Notify notifies all attached observers of the current state.
+func (s *ConcreteSubject) Notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.notifyLocked()
+}
+
+// notifyLocked sends the current state to every observer. The caller must hold s.mu.
+func (s *ConcreteSubject) notifyLocked() {
+	for _, o := range s.observers {
+		o.Update(s.state)
+	}
+}
+
+// GetState returns the state of the subject.
+func (s *ConcreteSubject) GetState() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// BehaviorSubject is a subject that sends its current state to every observer as soon as it is attached.
+type BehaviorSubject struct {
+	ConcreteSubject
+	hasState bool
+}
+
+// NewBehaviorSubject creates a new BehaviorSubject with the given initial state.
+func NewBehaviorSubject(initial string) *BehaviorSubject {
+	s := &BehaviorSubject{hasState: true}
+	s.state = initial
+	return s
+}
+
+// Attach attaches an observer to the subject and sends it the current state.
+// The current state is sent while the subject is locked, so the observer cannot miss a
+// notification that happens concurrently with the attach.
+func (s *BehaviorSubject) Attach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, observer)
+	if s.hasState {
+		observer.Update(s.state)
+	}
+}
+
+// SetState sets the state of the subject.
+func (s *BehaviorSubject) SetState(state string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	s.hasState = true
+}
+
+// event is a notification recorded by a ReplaySubject.
+type event struct {
+	state string
+	at    time.Time
+}
+
+// ReplaySubject is a subject that records its notifications and replays them to every observer as soon as it is attached.
+// It keeps at most size notifications, and only the notifications from the last window.
+// A size of zero or less means no limit on the number of notifications, and a window of zero or less means no limit on their age.
+type ReplaySubject struct {
+	ConcreteSubject
+	size   int
+	window time.Duration
+	now    func() time.Time
+	events []event
+}
+
+// NewReplaySubject creates a new ReplaySubject that replays the last size notifications from the last window.
+func NewReplaySubject(size int, window time.Duration) *ReplaySubject {
+	return &ReplaySubject{size: size, window: window, now: time.Now}
+}
+
+// Attach attaches an observer to the subject and replays the recorded notifications to it.
+func (s *ReplaySubject) Attach(observer Observer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.trim()
+	for _, e := range s.events {
+		observer.Update(e.state)
+	}
+	s.observers = append(s.observers, observer)
+}
+
+// Notify records the current state and notifies all attached observers of it.
+func (s *ReplaySubject) Notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event{state: s.state, at: s.now()})
+	s.trim()
+	s.notifyLocked()
+}
+
+// trim drops the notifications that are too old or beyond the size limit. The caller must hold s.mu.
+func (s *ReplaySubject) trim() {
+	start := 0
+	if s.window > 0 {
+		cutoff := s.now().Add(-s.window)
+		for start < len(s.events) && s.events[start].at.Before(cutoff) {
+			start++
+		}
+	}
+	if s.size > 0 && len(s.events)-start > s.size {
+		start = len(s.events) - s.size
+	}
+	if start > 0 {
+		s.events = append(s.events[:0], s.events[start:]...)
+	}
+}
+
+func main() {
+	behavior := NewBehaviorSubject("Idle")
+	behavior.Attach(&ConcreteObserver{name: "BehaviorObserverA"})
+	behavior.SetState("Loading")
+	behavior.Notify()
+	behavior.Attach(&ConcreteObserver{name: "BehaviorObserverB"})
+
+	replay := NewReplaySubject(2, time.Minute)
+	for _, state := range []string{"Hello", "Dear", "World"} {
+		replay.SetState(state)
+		replay.Notify()
+	}
+	replay.Attach(&ConcreteObserver{name: "ReplayObserver"})
+}
+
+`
+In this example, the Observer interface and the ConcreteObserver struct form the application's inner layer, while the ConcreteSubject, BehaviorSubject and ReplaySubject structs are part of the infrastructure layer. The main function is the outer layer, and it attaches observers both before and after the state of the subjects has changed.
+
+The BehaviorSubject struct sends its current state to an observer as soon as it is attached, so BehaviorObserverB sees "Loading" even though it was attached after the notification. The ReplaySubject struct records every notification, trims the recorded notifications to the last size entries and the last window of time, and replays them to an observer as soon as it is attached, so ReplayObserver sees "Dear" and "World".
+
+Both variants hold the subject's lock while they replay to a new observer and while they notify, so a late observer never misses or duplicates a notification that happens concurrently with the attach. As a consequence, observers must not call back into the subject from Update.
+
+This pattern is useful for UI and cache observers that can join at any time, as they receive the state they need without the subject knowing when they were attached.
+`
@@ -0,0 +1,349 @@
+`
+The transactional outbox pattern guarantees that a change to the database and the event announcing it are either both recorded or both discarded. The event is written to an outbox table in the same transaction as the change, and a relay later reads the outbox and publishes its events to observers, at least once, with an ID that lets observers discard duplicates.
+`
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Entity represents a business entity.
+type Entity struct {
+	ID   int
+	Name string
+}
+
+// EntityRepository is an interface for storing and retrieving entities.
+type EntityRepository interface {
+	Find(id int) (*Entity, error)
+	Save(entity *Entity) error
+}
+
+// Event is a state change recorded in the outbox.
+type Event struct {
+	// ID identifies the event, so observers can discard the events they already handled.
+	ID        string
+	Type      string
+	EntityID  int
+	Name      string
+	CreatedAt time.Time
+}
+
+// EventTypeEntityRenamed is the type of the event recorded when an entity is renamed.
+const EventTypeEntityRenamed = "entity renamed"
+
+// Outbox is an interface for recording events in the current transaction.
+type Outbox interface {
+	Add(event Event) error
+}
+
+// Transactor is an interface for running a function in a transaction that spans the entities and the outbox.
+// The transaction is committed if fn returns nil, and rolled back otherwise.
+type Transactor interface {
+	WithinTx(fn func(repo EntityRepository, outbox Outbox) error) error
+}
+
+// OutboxStore is an interface for reading the events that have not been published yet.
+type OutboxStore interface {
+	Pending(limit int) ([]Event, error)
+	MarkPublished(id string) error
+}
+
+// ErrNotFound is returned when an entity does not exist.
+var ErrNotFound = errors.New("entity not found")
+
+// MemoryStore is an in-memory implementation of Transactor and OutboxStore, standing in for a SQL database
+// with an entities table and an outbox table.
+type MemoryStore struct {
+	mu        sync.Mutex
+	entities  map[int]Entity
+	outbox    []Event
+	published map[string]bool
+}
+
+// NewMemoryStore creates a new MemoryStore containing the given entities.
+func NewMemoryStore(entities ...Entity) *MemoryStore {
+	s := &MemoryStore{entities: map[int]Entity{}, published: map[string]bool{}}
+	for _, e := range entities {
+		s.entities[e.ID] = e
+	}
+	return s
+}
+
+// WithinTx runs fn in a transaction. The writes made by fn are only visible once it returns nil.
+func (s *MemoryStore) WithinTx(fn func(repo EntityRepository, outbox Outbox) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx := &memoryTx{store: s, entities: map[int]Entity{}}
+	if err := fn(tx, tx); err != nil {
+		return err
+	}
+
+	for id, e := range tx.entities {
+		s.entities[id] = e
+	}
+	s.outbox = append(s.outbox, tx.events...)
+	return nil
+}
+
+// Pending returns up to limit events that have not been published yet, oldest first.
+func (s *MemoryStore) Pending(limit int) ([]Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var events []Event
+	for _, e := range s.outbox {
+		if len(events) == limit {
+			break
+		}
+		if !s.published[e.ID] {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// MarkPublished records that an event has been published.
+func (s *MemoryStore) MarkPublished(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.published[id] = true
+	return nil
+}
+
+// memoryTx is a transaction of a MemoryStore. It implements both EntityRepository and Outbox.
+// The caller of its methods holds the lock of the store.
+type memoryTx struct {
+	store    *MemoryStore
+	entities map[int]Entity
+	events   []Event
+}
+
+// Find retrieves an entity, including the changes made in the transaction.
+func (tx *memoryTx) Find(id int) (*Entity, error) {
+	if e, ok := tx.entities[id]; ok {
+		return &e, nil
+	}
+	if e, ok := tx.store.entities[id]; ok {
+		return &e, nil
+	}
+	return nil, ErrNotFound
+}
+
+// Save stages an entity.
+func (tx *memoryTx) Save(entity *Entity) error {
+	tx.entities[entity.ID] = *entity
+	return nil
+}
+
+// Add stages an event.
+func (tx *memoryTx) Add(event Event) error {
+	tx.events = append(tx.events, event)
+	return nil
+}
+
+// EntityService performs business logic on entities, recording an event for every change.
+type EntityService struct {
+	tx  Transactor
+	now func() time.Time
+}
+
+// NewEntityService creates a new EntityService with the given transactor.
+func NewEntityService(tx Transactor) *EntityService {
+	return &EntityService{tx: tx, now: time.Now}
+}
+
+// DoSomethingElse retrieves an entity, updates its name, and saves it together with an "entity renamed" event.
+func (s *EntityService) DoSomethingElse(id int, name string) (*Entity, error) {
+	var updated *Entity
+	err := s.tx.WithinTx(func(repo EntityRepository, outbox Outbox) error {
+		entity, err := repo.Find(id)
+		if err != nil {
+			return err
+		}
+
+		entity.Name = name
+
+		if err := repo.Save(entity); err != nil {
+			return err
+		}
+
+		eventID, err := newEventID()
+		if err != nil {
+			return err
+		}
+		updated = entity
+		return outbox.Add(Event{
+			ID:        eventID,
+			Type:      EventTypeEntityRenamed,
+			EntityID:  entity.ID,
+			Name:      entity.Name,
+			CreatedAt: s.now(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// newEventID returns a random event ID.
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(event Event) error
+}
+
+// Relay publishes the events of an outbox to observers.
+// An event is marked as published once every observer has handled it, so an event whose publication was interrupted
+// is published again: observers receive every event at least once.
+type Relay struct {
+	store     OutboxStore
+	observers []Observer
+	batchSize int
+}
+
+// NewRelay creates a new Relay that publishes the events of store to observers.
+func NewRelay(store OutboxStore, observers ...Observer) *Relay {
+	return &Relay{store: store, observers: observers, batchSize: 100}
+}
+
+// RunOnce publishes the pending events and returns the number of events published.
+// It stops at the first event an observer fails to handle, so events are published in order.
+func (r *Relay) RunOnce() (int, error) {
+	events, err := r.store.Pending(r.batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, event := range events {
+		for _, o := range r.observers {
+			if err := o.Update(event); err != nil {
+				return i, fmt.Errorf("publish event %s: %w", event.ID, err)
+			}
+		}
+		if err := r.store.MarkPublished(event.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(events), nil
+}
+
+// Run publishes the pending events every interval until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.RunOnce(); err != nil {
+			log.Printf("outbox relay: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// DedupObserver is an Observer that discards the events its wrapped observer has already handled.
+type DedupObserver struct {
+	mu       sync.Mutex
+	observer Observer
+	seen     map[string]bool
+}
+
+// NewDedupObserver creates a new DedupObserver wrapping observer.
+func NewDedupObserver(observer Observer) *DedupObserver {
+	return &DedupObserver{observer: observer, seen: map[string]bool{}}
+}
+
+// Update passes the event to the wrapped observer, unless it was already handled.
+func (o *DedupObserver) Update(event Event) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.seen[event.ID] {
+		return nil
+	}
+	if err := o.observer.Update(event); err != nil {
+		return err
+	}
+	o.seen[event.ID] = true
+	return nil
+}
+
+// ConcreteObserver is a concrete implementation of Observer.
+type ConcreteObserver struct{}
+
+// Update prints the event.
+func (o *ConcreteObserver) Update(event Event) error {
+	fmt.Printf("ConcreteObserver: %s: entity %d is now %q\n", event.Type, event.EntityID, event.Name)
+	return nil
+}
+
+// FlakyObserver is an Observer that fails every other event it receives.
+type FlakyObserver struct {
+	calls int
+}
+
+// Update fails every other call.
+func (o *FlakyObserver) Update(event Event) error {
+	o.calls++
+	if o.calls%2 == 1 {
+		return errors.New("temporarily unavailable")
+	}
+	return nil
+}
+
+func main() {
+	store := NewMemoryStore(Entity{ID: 123, Name: "My Entity"})
+	service := NewEntityService(store)
+
+	entity, err := service.DoSomethingElse(123, "New Name")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(entity)
+
+	// Neither the entity nor an event is recorded when the save fails.
+	if _, err := service.DoSomethingElse(456, "Missing"); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	// The first attempt reaches the ConcreteObserver but not the FlakyObserver, so the event is published again,
+	// and the DedupObserver keeps the ConcreteObserver from handling it twice.
+	relay := NewRelay(store, NewDedupObserver(&ConcreteObserver{}), &FlakyObserver{})
+	for {
+		n, err := relay.RunOnce()
+		if err != nil {
+			fmt.Println("Error:", err)
+			continue
+		}
+		if n == 0 {
+			break
+		}
+	}
+}
+
+`
+In this example, the Entity struct, the EntityRepository, Outbox and Transactor interfaces, and the EntityService struct form the application's inner layer, while the MemoryStore struct (standing in for a SQL database with an entities table and an outbox table), the Relay struct and the DedupObserver struct belong to the infrastructure layer. The main function is the outer layer, and it renames an entity and relays the resulting event to two observers.
+
+The DoSomethingElse method of the EntityService struct saves the renamed entity and adds an "entity renamed" event to the outbox in the same transaction, so if either write fails, neither is recorded. The Relay struct reads the pending events from the outbox, passes them to its observers in order, and marks an event as published only once every observer has handled it. If the relay fails (or the process crashes) in between, the event is published again, so observers receive every event at least once. Every event carries a random ID, and the DedupObserver struct uses it to discard the events its observer has already handled.
+
+This pattern allows the state of the database and the events published to observers to stay consistent, without requiring a distributed transaction between the database and the observers.
+`
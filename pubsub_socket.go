@@ -0,0 +1,730 @@
+`
+Cross-process publish/subscribe extends the observer pattern across process boundaries. A broker owns the subjects (called topics), and clients in other processes publish state changes to them and attach observers to them over a Unix domain socket or a localhost TCP connection.
+`
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// The protocol is a sequence of frames in both directions. Every frame is a 4-byte big-endian length followed by
+// that many bytes of JSON encoding a Message. The message types are:
+//
+//	hello       client -> broker  {"type":"hello","client":"<id>"}, sent first on every connection
+//	subscribe   client -> broker  {"type":"subscribe","topic":"<t>","seq":<n>,"epoch":"<e>"}, replays the events after
+//	                              max(n, last ack), or every retained event if e is not the epoch of the broker
+//	unsubscribe client -> broker  {"type":"unsubscribe","topic":"<t>"}
+//	publish     client -> broker  {"type":"publish","topic":"<t>","state":"<s>"}
+//	event       broker -> client  {"type":"event","topic":"<t>","seq":<n>,"epoch":"<e>","state":"<s>"}
+//	ack         client -> broker  {"type":"ack","topic":"<t>","seq":<n>}, after the event has been handled
+//	ping        client -> broker  {"type":"ping"}
+//	pong        broker -> client  {"type":"pong"}
+//	error       broker -> client  {"type":"error","error":"<message>"}
+//
+// Sequence numbers are assigned by the broker per topic, starting at 1. The broker keeps them in memory, so they start
+// again at 1 when it restarts: they are only meaningful within the epoch of the broker, a random ID chosen when it is
+// created and sent with every event.
+const (
+	MessageHello       = "hello"
+	MessageSubscribe   = "subscribe"
+	MessageUnsubscribe = "unsubscribe"
+	MessagePublish     = "publish"
+	MessageEvent       = "event"
+	MessageAck         = "ack"
+	MessagePing        = "ping"
+	MessagePong        = "pong"
+	MessageError       = "error"
+)
+
+// maxFrameSize is the largest frame accepted by readFrame.
+const maxFrameSize = 1 << 20
+
+// ErrNotConnected is returned when a client is asked to send a message while it is disconnected from the broker.
+var ErrNotConnected = errors.New("pubsub: not connected")
+
+// Message is a frame of the protocol.
+type Message struct {
+	Type   string `json:"type"`
+	Client string `json:"client,omitempty"`
+	Topic  string `json:"topic,omitempty"`
+	Seq    uint64 `json:"seq,omitempty"`
+	Epoch  string `json:"epoch,omitempty"`
+	State  string `json:"state,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// writeFrame writes msg to w as a length-prefixed JSON frame.
+func writeFrame(w io.Writer, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	frame := make([]byte, 4+len(body))
+	binary.BigEndian.PutUint32(frame, uint32(len(body)))
+	copy(frame[4:], body)
+	_, err = w.Write(frame)
+	return err
+}
+
+// readFrame reads a length-prefixed JSON frame from r.
+func readFrame(r io.Reader) (Message, error) {
+	var msg Message
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return msg, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length > maxFrameSize {
+		return msg, fmt.Errorf("pubsub: frame of %d bytes exceeds limit", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return msg, err
+	}
+	err := json.Unmarshal(body, &msg)
+	return msg, err
+}
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// BrokerOptions configures a Broker.
+type BrokerOptions struct {
+	// History is the number of events retained per topic for resuming subscribers.
+	History int
+	// IdleTimeout is the time after which a connection that has sent nothing, not even a ping, is closed.
+	IdleTimeout time.Duration
+	// QueueSize is the number of outgoing live messages buffered per connection. A connection whose queue is full is
+	// closed, and the client resumes from its last acknowledged event when it reconnects. The replay of a subscription
+	// is not counted against the queue.
+	QueueSize int
+}
+
+// topic is a subject owned by the broker.
+type topic struct {
+	seq         uint64
+	history     []Message
+	subscribers map[*brokerConn]struct{}
+}
+
+// Broker is a server that owns topics and relays the events published to them to subscribed clients.
+type Broker struct {
+	mu        sync.Mutex
+	opts      BrokerOptions
+	epoch     string
+	topics    map[string]*topic
+	acks      map[string]map[string]uint64
+	conns     map[*brokerConn]struct{}
+	listeners []net.Listener
+	closed    bool
+}
+
+// NewBroker creates a new Broker with the given options.
+func NewBroker(opts BrokerOptions) *Broker {
+	if opts.History <= 0 {
+		opts.History = 1024
+	}
+	if opts.IdleTimeout <= 0 {
+		opts.IdleTimeout = 30 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 256
+	}
+	epoch := make([]byte, 8)
+	rand.Read(epoch)
+	return &Broker{
+		opts:   opts,
+		epoch:  hex.EncodeToString(epoch),
+		topics: map[string]*topic{},
+		acks:   map[string]map[string]uint64{},
+		conns:  map[*brokerConn]struct{}{},
+	}
+}
+
+// Serve accepts connections on l until the broker is closed.
+func (b *Broker) Serve(l net.Listener) error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return net.ErrClosed
+	}
+	b.listeners = append(b.listeners, l)
+	b.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			b.mu.Lock()
+			closed := b.closed
+			b.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go b.handle(conn)
+	}
+}
+
+// Close stops accepting connections and closes every open connection.
+func (b *Broker) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	listeners := b.listeners
+	conns := make([]*brokerConn, 0, len(b.conns))
+	for bc := range b.conns {
+		conns = append(conns, bc)
+	}
+	b.mu.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+	for _, bc := range conns {
+		bc.close()
+	}
+	return nil
+}
+
+// DropConnections closes every open connection without closing the broker, as a network failure would.
+func (b *Broker) DropConnections() {
+	b.mu.Lock()
+	conns := make([]*brokerConn, 0, len(b.conns))
+	for bc := range b.conns {
+		conns = append(conns, bc)
+	}
+	b.mu.Unlock()
+
+	for _, bc := range conns {
+		bc.close()
+	}
+}
+
+// handle reads the messages of a connection until it fails or is closed.
+func (b *Broker) handle(conn net.Conn) {
+	bc := &brokerConn{
+		conn:   conn,
+		out:    make(chan Message, b.opts.QueueSize),
+		replay: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	b.mu.Lock()
+	b.conns[bc] = struct{}{}
+	b.mu.Unlock()
+
+	go bc.writeLoop()
+	defer b.drop(bc)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(b.opts.IdleTimeout))
+		msg, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		b.dispatch(bc, msg)
+	}
+}
+
+// dispatch handles a message received on bc.
+func (b *Broker) dispatch(bc *brokerConn, msg Message) {
+	switch msg.Type {
+	case MessageHello:
+		b.mu.Lock()
+		bc.client = msg.Client
+		b.mu.Unlock()
+	case MessageSubscribe:
+		b.subscribe(bc, msg.Topic, msg.Seq, msg.Epoch)
+	case MessageUnsubscribe:
+		b.mu.Lock()
+		if t, ok := b.topics[msg.Topic]; ok {
+			delete(t.subscribers, bc)
+		}
+		b.mu.Unlock()
+	case MessagePublish:
+		b.publish(msg.Topic, msg.State)
+	case MessageAck:
+		b.mu.Lock()
+		if bc.client != "" {
+			acks := b.acks[bc.client]
+			if acks == nil {
+				acks = map[string]uint64{}
+				b.acks[bc.client] = acks
+			}
+			if msg.Seq > acks[msg.Topic] {
+				acks[msg.Topic] = msg.Seq
+			}
+		}
+		b.mu.Unlock()
+	case MessagePing:
+		bc.enqueue(Message{Type: MessagePong})
+	default:
+		bc.enqueue(Message{Type: MessageError, Error: fmt.Sprintf("unknown message type %q", msg.Type)})
+	}
+}
+
+// subscribe subscribes bc to a topic, first replaying the retained events after the last one the client acknowledged.
+// A sequence number of another epoch refers to the events of a previous broker, so it is ignored. The replay is
+// handed to the writer of bc as a whole, so it cannot overflow the queue of live messages.
+func (b *Broker) subscribe(bc *brokerConn, name string, seq uint64, epoch string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if epoch != b.epoch {
+		seq = 0
+	}
+	if ack := b.acks[bc.client][name]; bc.client != "" && ack > seq {
+		seq = ack
+	}
+	t := b.topic(name)
+	var events []Message
+	for _, event := range t.history {
+		if event.Seq > seq {
+			events = append(events, event)
+		}
+	}
+	bc.enqueueReplay(events)
+	t.subscribers[bc] = struct{}{}
+}
+
+// publish assigns the next sequence number of a topic to state and sends it to the subscribers of the topic.
+func (b *Broker) publish(name, state string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topic(name)
+	t.seq++
+	event := Message{Type: MessageEvent, Topic: name, Seq: t.seq, Epoch: b.epoch, State: state}
+	t.history = append(t.history, event)
+	if len(t.history) > b.opts.History {
+		t.history = t.history[len(t.history)-b.opts.History:]
+	}
+	for bc := range t.subscribers {
+		bc.enqueue(event)
+	}
+}
+
+// topic returns the topic with the given name, creating it if needed. The caller must hold b.mu.
+func (b *Broker) topic(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{subscribers: map[*brokerConn]struct{}{}}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// drop removes a closed connection from the broker.
+func (b *Broker) drop(bc *brokerConn) {
+	bc.close()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.conns, bc)
+	for _, t := range b.topics {
+		delete(t.subscribers, bc)
+	}
+}
+
+// brokerConn is a client connection accepted by the broker.
+type brokerConn struct {
+	conn      net.Conn
+	client    string
+	out       chan Message
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// pending holds the replayed events not yet sent, which are sent before any message of out. replay is signaled
+	// when events are added to it.
+	mu      sync.Mutex
+	pending []Message
+	replay  chan struct{}
+}
+
+// enqueueReplay queues the replayed events of a subscription for sending. They are sent before every message queued
+// after them, whatever the number of events.
+func (bc *brokerConn) enqueueReplay(events []Message) {
+	if len(events) == 0 {
+		return
+	}
+	bc.mu.Lock()
+	bc.pending = append(bc.pending, events...)
+	bc.mu.Unlock()
+	select {
+	case bc.replay <- struct{}{}:
+	default:
+	}
+}
+
+// sendReplay sends the pending replayed events, blocking on the connection as long as needed.
+func (bc *brokerConn) sendReplay() error {
+	bc.mu.Lock()
+	events := bc.pending
+	bc.pending = nil
+	bc.mu.Unlock()
+
+	for _, event := range events {
+		if err := writeFrame(bc.conn, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue queues a live msg for sending, closing the connection if its queue is full.
+func (bc *brokerConn) enqueue(msg Message) {
+	select {
+	case <-bc.done:
+	case bc.out <- msg:
+	default:
+		bc.close()
+	}
+}
+
+// writeLoop sends the queued messages until the connection is closed.
+func (bc *brokerConn) writeLoop() {
+	for {
+		select {
+		case <-bc.done:
+			return
+		case <-bc.replay:
+			if err := bc.sendReplay(); err != nil {
+				bc.close()
+				return
+			}
+		case msg := <-bc.out:
+			// A replay queued before msg, such as the replay of the subscription msg is an event of, goes first.
+			if err := bc.sendReplay(); err != nil {
+				bc.close()
+				return
+			}
+			if err := writeFrame(bc.conn, msg); err != nil {
+				bc.close()
+				return
+			}
+		}
+	}
+}
+
+// close closes the connection.
+func (bc *brokerConn) close() {
+	bc.closeOnce.Do(func() {
+		close(bc.done)
+		bc.conn.Close()
+	})
+}
+
+// ClientOptions configures a Client.
+type ClientOptions struct {
+	// Heartbeat is the interval between pings. The connection is considered lost after three intervals without a frame from the broker.
+	Heartbeat time.Duration
+	// ReconnectDelay is the delay between connection attempts.
+	ReconnectDelay time.Duration
+}
+
+// Client is a connection to a Broker that reconnects automatically and resumes its subscriptions
+// from the last event it acknowledged.
+type Client struct {
+	network string
+	address string
+	id      string
+	opts    ClientOptions
+
+	mu        sync.Mutex
+	conn      net.Conn
+	observers map[string]Observer
+	lastSeq   map[string]uint64
+	epoch     string
+	closed    bool
+	done      chan struct{}
+
+	wmu sync.Mutex
+}
+
+// Dial creates a new Client identified by id, connected to the broker at address.
+// The client keeps reconnecting in the background until it is closed.
+func Dial(network, address, id string, opts ClientOptions) *Client {
+	if opts.Heartbeat <= 0 {
+		opts.Heartbeat = 5 * time.Second
+	}
+	if opts.ReconnectDelay <= 0 {
+		opts.ReconnectDelay = time.Second
+	}
+	c := &Client{
+		network:   network,
+		address:   address,
+		id:        id,
+		opts:      opts,
+		observers: map[string]Observer{},
+		lastSeq:   map[string]uint64{},
+		done:      make(chan struct{}),
+	}
+	go c.run()
+	return c
+}
+
+// Subscribe attaches observer to a topic of the broker.
+// The subscription survives reconnections, so it succeeds even while the client is disconnected.
+func (c *Client) Subscribe(topic string, observer Observer) error {
+	c.mu.Lock()
+	c.observers[topic] = observer
+	seq, epoch := c.lastSeq[topic], c.epoch
+	c.mu.Unlock()
+
+	err := c.send(Message{Type: MessageSubscribe, Topic: topic, Seq: seq, Epoch: epoch})
+	if errors.Is(err, ErrNotConnected) {
+		return nil
+	}
+	return err
+}
+
+// Unsubscribe detaches the observer of a topic.
+func (c *Client) Unsubscribe(topic string) error {
+	c.mu.Lock()
+	delete(c.observers, topic)
+	c.mu.Unlock()
+
+	err := c.send(Message{Type: MessageUnsubscribe, Topic: topic})
+	if errors.Is(err, ErrNotConnected) {
+		return nil
+	}
+	return err
+}
+
+// Publish publishes a state change to a topic of the broker.
+// Publishing is fire-and-forget: a nil error means that the message was written to the connection, not that the
+// broker received it, so a state change published just before the connection fails can be lost. Publish returns
+// ErrNotConnected while the client is disconnected.
+func (c *Client) Publish(topic, state string) error {
+	return c.send(Message{Type: MessagePublish, Topic: topic, State: state})
+}
+
+// Close closes the client and its connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+	if c.conn != nil {
+		return c.conn.Close()
+	}
+	return nil
+}
+
+// run connects to the broker and serves the connection, reconnecting until the client is closed.
+func (c *Client) run() {
+	for {
+		conn, err := net.Dial(c.network, c.address)
+		if err == nil {
+			c.serve(conn)
+		}
+		select {
+		case <-c.done:
+			return
+		case <-time.After(c.opts.ReconnectDelay):
+		}
+	}
+}
+
+// serve greets the broker, restores the subscriptions and handles the frames received on conn until it fails.
+func (c *Client) serve(conn net.Conn) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		conn.Close()
+		return
+	}
+	c.conn = conn
+	resume := make(map[string]uint64, len(c.observers))
+	for topic := range c.observers {
+		resume[topic] = c.lastSeq[topic]
+	}
+	epoch := c.epoch
+	c.mu.Unlock()
+
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.Close()
+	}()
+
+	if err := c.send(Message{Type: MessageHello, Client: c.id}); err != nil {
+		return
+	}
+	for topic, seq := range resume {
+		if err := c.send(Message{Type: MessageSubscribe, Topic: topic, Seq: seq, Epoch: epoch}); err != nil {
+			return
+		}
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go c.heartbeat(stop)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(3 * c.opts.Heartbeat))
+		msg, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+		switch msg.Type {
+		case MessageEvent:
+			c.deliver(msg)
+		case MessageError:
+			log.Printf("pubsub: broker error: %s", msg.Error)
+		}
+	}
+}
+
+// deliver passes an event to the observer of its topic, skipping events that were already delivered, and acknowledges it.
+// An event of a new epoch comes from a restarted broker, whose sequence numbers started again, so the sequence numbers
+// of the previous epoch are forgotten.
+func (c *Client) deliver(msg Message) {
+	c.mu.Lock()
+	if msg.Epoch != c.epoch {
+		c.epoch = msg.Epoch
+		c.lastSeq = map[string]uint64{}
+	}
+	observer, ok := c.observers[msg.Topic]
+	duplicate := msg.Seq <= c.lastSeq[msg.Topic]
+	c.mu.Unlock()
+	if !ok || duplicate {
+		return
+	}
+
+	observer.Update(msg.State)
+
+	c.mu.Lock()
+	c.lastSeq[msg.Topic] = msg.Seq
+	c.mu.Unlock()
+	c.send(Message{Type: MessageAck, Topic: msg.Topic, Seq: msg.Seq})
+}
+
+// heartbeat pings the broker until stop is closed.
+func (c *Client) heartbeat(stop chan struct{}) {
+	ticker := time.NewTicker(c.opts.Heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := c.send(Message{Type: MessagePing}); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send writes msg to the current connection.
+func (c *Client) send(msg Message) error {
+	c.mu.Lock()
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return ErrNotConnected
+	}
+
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	conn.SetWriteDeadline(time.Now().Add(c.opts.Heartbeat))
+	return writeFrame(conn, msg)
+}
+
+// ChannelObserver is an Observer that forwards every state to a channel.
+type ChannelObserver struct {
+	name   string
+	states chan string
+}
+
+// Update forwards the state to the channel.
+func (o *ChannelObserver) Update(state string) {
+	o.states <- fmt.Sprintf("%s: %s", o.name, state)
+}
+
+func main() {
+	dir, err := os.MkdirTemp("", "pubsub")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	address := filepath.Join(dir, "broker.sock")
+
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	broker := NewBroker(BrokerOptions{IdleTimeout: time.Second})
+	go broker.Serve(listener)
+	defer broker.Close()
+
+	opts := ClientOptions{Heartbeat: 100 * time.Millisecond, ReconnectDelay: 50 * time.Millisecond}
+	publisher := Dial("unix", address, "publisher", opts)
+	defer publisher.Close()
+	subscriber := Dial("unix", address, "subscriber", opts)
+	defer subscriber.Close()
+
+	observer := &ChannelObserver{name: "RemoteObserver", states: make(chan string, 16)}
+	subscriber.Subscribe("greeting", observer)
+
+	publish := func(state string) {
+		for publisher.Publish("greeting", state) != nil {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// Wait until the subscription is active before publishing.
+	time.Sleep(200 * time.Millisecond)
+	publish("Hello")
+	fmt.Println(<-observer.states)
+
+	// The events published while the subscriber is disconnected are replayed when it reconnects.
+	broker.DropConnections()
+	publish("Dear")
+	publish("World")
+	fmt.Println(<-observer.states)
+	fmt.Println(<-observer.states)
+
+	// A restarted broker numbers its events from 1 again, in a new epoch.
+	broker.Close()
+	listener, err = net.Listen("unix", address)
+	if err != nil {
+		log.Fatal(err)
+	}
+	broker = NewBroker(BrokerOptions{IdleTimeout: time.Second})
+	go broker.Serve(listener)
+	defer broker.Close()
+
+	time.Sleep(200 * time.Millisecond)
+	publish("Again")
+	fmt.Println(<-observer.states)
+}
+
+`
+In this example, the Observer interface and the ChannelObserver struct form the application's inner layer, while the Broker and Client structs are part of the infrastructure layer. The main function is the outer layer, and it starts a broker on a Unix domain socket, publishes state changes from one client, and receives them in an observer attached through another client, including the state changes published while the connections were dropped and the one published after the broker restarts.
+
+The broker and the clients exchange length-prefixed JSON frames, as documented next to the message type constants. The broker assigns a sequence number to every event of a topic and retains the last History events. Clients acknowledge every event once their observer has handled it, and when a client reconnects it sends hello with its identity and subscribes again, so the broker replays the events after the last one the client acknowledged. The client also remembers the last sequence number of every topic, so it never delivers an event twice to its observer.
+
+The broker keeps its sequence numbers in memory, so a restarted broker numbers the events of every topic from 1 again. Every broker has an epoch, a random ID chosen when it is created and sent with every event and every subscription: when a client receives an event of a new epoch, it forgets the sequence numbers of the previous one, and a broker ignores the sequence number of a subscription made in another epoch, so the events published after a restart are delivered instead of being taken for duplicates. Publishing is fire-and-forget, so a state change published just before a connection fails can be lost; a publisher that cannot lose state changes needs an acknowledgment from the broker, which this example does not implement.
+
+Clients send a ping every Heartbeat and consider the connection lost after three intervals without a frame, while the broker closes connections that have been silent for IdleTimeout. A slow client whose outgoing queue fills up is disconnected instead of slowing down the publishers, and it catches up through the replay when it reconnects. The replay does not go through that queue: the retained events are handed to the writer of the connection as a whole and sent before any live event, however many there are, so a client that missed more events than QueueSize is not disconnected by its own replay over and over. The same code works over localhost TCP by passing "tcp" and a host:port address to net.Listen and Dial.
+
+This pattern allows observers in one process to be notified of state changes in another process, without the subject knowing where its observers live.
+`
@@ -0,0 +1,391 @@
+`
+Reactive operators are an extension of the observer pattern that treats the notifications of a subject as a stream of values. Operators such as Map, Filter, Debounce or CombineLatest take one or more observables and return a new observable, so derived state can be composed from several subjects instead of being maintained by hand.
+`
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Observable is a source of values that observers can subscribe to.
+// Subscribing returns a function that unsubscribes the observer, and unsubscribing from a derived observable unsubscribes it from its sources.
+type Observable[T any] func(observer func(T)) (unsubscribe func())
+
+// Subject is a subject that notifies its observers of every state change.
+type Subject[T any] struct {
+	mu        sync.Mutex
+	observers map[int]func(T)
+	nextID    int
+	state     T
+}
+
+// NewSubject creates a new Subject with the given initial state.
+func NewSubject[T any](initial T) *Subject[T] {
+	return &Subject[T]{observers: map[int]func(T){}, state: initial}
+}
+
+// Observable returns the subject as an Observable.
+func (s *Subject[T]) Observable() Observable[T] {
+	return func(observer func(T)) func() {
+		s.mu.Lock()
+		id := s.nextID
+		s.nextID++
+		s.observers[id] = observer
+		s.mu.Unlock()
+
+		return func() {
+			s.mu.Lock()
+			delete(s.observers, id)
+			s.mu.Unlock()
+		}
+	}
+}
+
+// Notify notifies all subscribed observers of the current state.
+func (s *Subject[T]) Notify() {
+	s.mu.Lock()
+	state := s.state
+	ids := make([]int, 0, len(s.observers))
+	for id := range s.observers {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	observers := make([]func(T), len(ids))
+	for i, id := range ids {
+		observers[i] = s.observers[id]
+	}
+	s.mu.Unlock()
+
+	for _, o := range observers {
+		o(state)
+	}
+}
+
+// GetState returns the state of the subject.
+func (s *Subject[T]) GetState() T {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// SetState sets the state of the subject.
+func (s *Subject[T]) SetState(state T) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+}
+
+// Observers returns the number of subscribed observers.
+func (s *Subject[T]) Observers() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.observers)
+}
+
+// Map returns an observable that emits fn applied to every value of src.
+func Map[T, R any](src Observable[T], fn func(T) R) Observable[R] {
+	return func(observer func(R)) func() {
+		return src(func(v T) { observer(fn(v)) })
+	}
+}
+
+// Filter returns an observable that emits the values of src for which keep returns true.
+func Filter[T any](src Observable[T], keep func(T) bool) Observable[T] {
+	return func(observer func(T)) func() {
+		return src(func(v T) {
+			if keep(v) {
+				observer(v)
+			}
+		})
+	}
+}
+
+// DistinctUntilChanged returns an observable that emits the values of src that differ from the previous one.
+func DistinctUntilChanged[T comparable](src Observable[T]) Observable[T] {
+	return func(observer func(T)) func() {
+		var mu sync.Mutex
+		var last T
+		seen := false
+		return src(func(v T) {
+			mu.Lock()
+			changed := !seen || v != last
+			seen, last = true, v
+			mu.Unlock()
+			if changed {
+				observer(v)
+			}
+		})
+	}
+}
+
+// Scan returns an observable that emits the accumulation of the values of src, starting from seed.
+func Scan[T, A any](src Observable[T], seed A, fn func(acc A, v T) A) Observable[A] {
+	return func(observer func(A)) func() {
+		var mu sync.Mutex
+		acc := seed
+		return src(func(v T) {
+			mu.Lock()
+			acc = fn(acc, v)
+			next := acc
+			mu.Unlock()
+			observer(next)
+		})
+	}
+}
+
+// Debounce returns an observable that emits the latest value of src once src has been quiet for d.
+// No value is emitted once unsubscribing has returned, so the observer must not unsubscribe from within itself.
+func Debounce[T any](src Observable[T], d time.Duration, clock Clock) Observable[T] {
+	return func(observer func(T)) func() {
+		// emitMu is held while the observer is called, so unsubscribing waits for an emission in progress.
+		var emitMu sync.Mutex
+		var mu sync.Mutex
+		var timer Timer
+		var generation uint64
+		stopped := false
+		unsubscribe := src(func(v T) {
+			mu.Lock()
+			defer mu.Unlock()
+			if stopped {
+				return
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			generation++
+			gen := generation
+			timer = clock.AfterFunc(d, func() {
+				emitMu.Lock()
+				defer emitMu.Unlock()
+				// The timer may have fired while unsubscribe or a newer value was stopping it.
+				mu.Lock()
+				current := !stopped && gen == generation
+				mu.Unlock()
+				if current {
+					observer(v)
+				}
+			})
+		})
+		return func() {
+			unsubscribe()
+			emitMu.Lock()
+			defer emitMu.Unlock()
+			mu.Lock()
+			defer mu.Unlock()
+			stopped = true
+			if timer != nil {
+				timer.Stop()
+			}
+		}
+	}
+}
+
+// Throttle returns an observable that emits a value of src and then ignores the values of src for d.
+func Throttle[T any](src Observable[T], d time.Duration, clock Clock) Observable[T] {
+	return func(observer func(T)) func() {
+		var mu sync.Mutex
+		var next time.Time
+		return src(func(v T) {
+			mu.Lock()
+			now := clock.Now()
+			emit := !now.Before(next)
+			if emit {
+				next = now.Add(d)
+			}
+			mu.Unlock()
+			if emit {
+				observer(v)
+			}
+		})
+	}
+}
+
+// Merge returns an observable that emits the values of all srcs.
+func Merge[T any](srcs ...Observable[T]) Observable[T] {
+	return func(observer func(T)) func() {
+		unsubscribes := make([]func(), len(srcs))
+		for i, src := range srcs {
+			unsubscribes[i] = src(observer)
+		}
+		return func() {
+			for _, unsubscribe := range unsubscribes {
+				unsubscribe()
+			}
+		}
+	}
+}
+
+// CombineLatest returns an observable that emits fn applied to the latest values of a and b whenever either of them emits.
+// Nothing is emitted until both a and b have emitted at least once.
+func CombineLatest[A, B, R any](a Observable[A], b Observable[B], fn func(A, B) R) Observable[R] {
+	return func(observer func(R)) func() {
+		var mu sync.Mutex
+		var latestA A
+		var latestB B
+		var hasA, hasB bool
+
+		emit := func() {
+			if !hasA || !hasB {
+				mu.Unlock()
+				return
+			}
+			v := fn(latestA, latestB)
+			mu.Unlock()
+			observer(v)
+		}
+
+		unsubscribeA := a(func(v A) {
+			mu.Lock()
+			latestA, hasA = v, true
+			emit()
+		})
+		unsubscribeB := b(func(v B) {
+			mu.Lock()
+			latestB, hasB = v, true
+			emit()
+		})
+		return func() {
+			unsubscribeA()
+			unsubscribeB()
+		}
+	}
+}
+
+// Clock is an interface for the source of time used by the time-based operators.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is an interface for a function scheduled by a Clock.
+type Timer interface {
+	Stop() bool
+}
+
+// RealClock is a Clock backed by the time package.
+type RealClock struct{}
+
+// Now returns the current time.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls f in its own goroutine after d.
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// FakeClock is a Clock for tests whose time only moves when Advance is called.
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a new FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the current time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// AfterFunc schedules f to be called when the clock is advanced past d from now.
+func (c *FakeClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, at: c.now.Add(d), f: f}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d, calling the scheduled functions that are due, in order, on the calling goroutine.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	target := c.now.Add(d)
+	for {
+		sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+		if len(c.timers) == 0 || c.timers[0].at.After(target) {
+			break
+		}
+		t := c.timers[0]
+		c.timers = c.timers[1:]
+		c.now = t.at
+		c.mu.Unlock()
+		t.f()
+		c.mu.Lock()
+	}
+	c.now = target
+	c.mu.Unlock()
+}
+
+// fakeTimer is a function scheduled by a FakeClock.
+type fakeTimer struct {
+	clock *FakeClock
+	at    time.Time
+	f     func()
+}
+
+// Stop cancels the timer, reporting whether it was still pending.
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, other := range c.timers {
+		if other == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func main() {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	firstName := NewSubject("Ada")
+	lastName := NewSubject("Lovelace")
+
+	fullName := DistinctUntilChanged(CombineLatest(firstName.Observable(), lastName.Observable(), func(first, last string) string {
+		return first + " " + last
+	}))
+	unsubscribe := Debounce(fullName, 100*time.Millisecond, clock)(func(name string) {
+		fmt.Println("Full name:", name)
+	})
+
+	length := Scan(Map(Filter(firstName.Observable(), func(s string) bool { return s != "" }), func(s string) int { return len(s) }), 0, func(total, n int) int { return total + n })
+	stopLength := Throttle(length, time.Second, clock)(func(total int) {
+		fmt.Println("Total length of first names:", total)
+	})
+
+	firstName.Notify()
+	lastName.Notify()
+	clock.Advance(50 * time.Millisecond)
+	firstName.SetState("Grace")
+	firstName.Notify()
+	lastName.SetState("Hopper")
+	lastName.Notify()
+	clock.Advance(100 * time.Millisecond)
+
+	unsubscribe()
+	stopLength()
+	fmt.Println("Observers left:", firstName.Observers(), lastName.Observers())
+}
+
+`
+In this example, the Subject struct and the Observable type form the application's inner layer, while the operators (Map, Filter, DistinctUntilChanged, Scan, Debounce, Throttle, Merge and CombineLatest) compose observables into new ones. The main function is the outer layer, and it derives a debounced full name from two subjects and a throttled running total from one of them.
+
+An Observable is a function that subscribes an observer and returns a function that unsubscribes it. Every operator returns an observable that subscribes to its sources only when it is itself subscribed to, and that unsubscribes from its sources when it is unsubscribed from, so the subjects have no observers left once the derived observables are no longer used.
+
+The time-based operators (Debounce and Throttle) get the time from a Clock. The RealClock struct uses the time package, while the FakeClock struct only moves when Advance is called, so the behavior of the operators can be tested deterministically. In the example, the full name is only emitted once, after the subjects have been quiet for 100 milliseconds.
+
+This pattern allows derived state to be declared once from its sources, instead of being recomputed by hand in every observer.
+`
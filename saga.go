@@ -0,0 +1,568 @@
+`
+The saga pattern (or process manager) coordinates a long-running workflow made of several steps, each of which is a local action that can be undone by a compensating action. The saga reacts to events to move from one step to the next, and when a step fails or times out, the compensating actions of the steps that already took effect are run in reverse order.
+`
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Event is a message published on the event bus.
+type Event struct {
+	Type string
+	// CorrelationID is the ID of the saga instance the event belongs to, if any.
+	CorrelationID string
+	EntityID      int
+}
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(event Event)
+}
+
+// EventBus is a subject that notifies the observers subscribed to an event type of every event of that type.
+// Events published while another event is being delivered are queued and delivered afterwards, in order,
+// so observers never receive an event while they are still handling another one.
+type EventBus struct {
+	mu          sync.Mutex
+	observers   map[string][]Observer
+	queue       []Event
+	dispatching bool
+}
+
+// NewEventBus creates a new EventBus.
+func NewEventBus() *EventBus {
+	return &EventBus{observers: map[string][]Observer{}}
+}
+
+// Subscribe subscribes an observer to an event type.
+func (b *EventBus) Subscribe(eventType string, observer Observer) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.observers[eventType] = append(b.observers[eventType], observer)
+}
+
+// Publish delivers an event to the observers subscribed to its type.
+func (b *EventBus) Publish(event Event) {
+	b.mu.Lock()
+	b.queue = append(b.queue, event)
+	if b.dispatching {
+		b.mu.Unlock()
+		return
+	}
+	b.dispatching = true
+
+	for len(b.queue) > 0 {
+		next := b.queue[0]
+		b.queue = b.queue[1:]
+		observers := append([]Observer(nil), b.observers[next.Type]...)
+		b.mu.Unlock()
+
+		for _, o := range observers {
+			o.Update(next)
+		}
+
+		b.mu.Lock()
+	}
+	b.dispatching = false
+	b.mu.Unlock()
+}
+
+// SagaStatus is the status of a saga instance.
+type SagaStatus string
+
+const (
+	// SagaRunning means the saga is executing its steps.
+	SagaRunning SagaStatus = "running"
+	// SagaCompleted means every step of the saga succeeded.
+	SagaCompleted SagaStatus = "completed"
+	// SagaCompensating means a step failed and the compensating actions are running.
+	SagaCompensating SagaStatus = "compensating"
+	// SagaCompensated means a step failed and the compensating actions have run.
+	SagaCompensated SagaStatus = "compensated"
+)
+
+// SagaInstance is the persisted state of a running saga.
+type SagaInstance struct {
+	ID       string
+	Saga     string
+	Status   SagaStatus
+	Step     int
+	EntityID int
+	Deadline time.Time
+	Error    string
+	// Undo is the index of the next step to compensate while the saga is compensating.
+	Undo int
+}
+
+// Step is a step of a saga.
+type Step struct {
+	Name string
+	// Action performs the step, usually by publishing a command. It is run again when the engine recovers an
+	// instance that was interrupted in this step, so it must be idempotent.
+	Action func(instance *SagaInstance) error
+	// Await is the event type that completes the step. If empty, the step completes when Action returns.
+	Await string
+	// FailOn is the event type that fails the step.
+	FailOn string
+	// Timeout is the time to wait for the Await event before the step fails. Zero means no timeout.
+	Timeout time.Duration
+	// Compensate undoes the step. It is run when a later step fails, or when this step times out. It is run again
+	// when the engine recovers an instance that was interrupted while compensating the step, so it must be idempotent.
+	Compensate func(instance *SagaInstance) error
+}
+
+// SagaDefinition describes a saga.
+type SagaDefinition struct {
+	Name string
+	// Trigger is the event type that starts a new instance of the saga.
+	Trigger string
+	Steps   []Step
+}
+
+// ErrSagaNotFound is returned when a saga instance does not exist.
+var ErrSagaNotFound = errors.New("saga instance not found")
+
+// SagaStore is an interface for persisting saga instances.
+type SagaStore interface {
+	Save(instance *SagaInstance) error
+	Load(id string) (*SagaInstance, error)
+	Running() ([]*SagaInstance, error)
+}
+
+// MemorySagaStore is an in-memory implementation of SagaStore that keeps the serialized form of every instance.
+type MemorySagaStore struct {
+	mu        sync.Mutex
+	instances map[string][]byte
+}
+
+// NewMemorySagaStore creates a new MemorySagaStore.
+func NewMemorySagaStore() *MemorySagaStore {
+	return &MemorySagaStore{instances: map[string][]byte{}}
+}
+
+// Save stores an instance.
+func (s *MemorySagaStore) Save(instance *SagaInstance) error {
+	data, err := json.Marshal(instance)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.instances[instance.ID] = data
+	return nil
+}
+
+// Load retrieves an instance.
+func (s *MemorySagaStore) Load(id string) (*SagaInstance, error) {
+	s.mu.Lock()
+	data, ok := s.instances[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrSagaNotFound
+	}
+	var instance SagaInstance
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, err
+	}
+	return &instance, nil
+}
+
+// Running retrieves the instances that are running or compensating.
+func (s *MemorySagaStore) Running() ([]*SagaInstance, error) {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.instances))
+	for id := range s.instances {
+		ids = append(ids, id)
+	}
+	s.mu.Unlock()
+
+	var running []*SagaInstance
+	for _, id := range ids {
+		instance, err := s.Load(id)
+		if err != nil {
+			return nil, err
+		}
+		if instance.Status == SagaRunning || instance.Status == SagaCompensating {
+			running = append(running, instance)
+		}
+	}
+	return running, nil
+}
+
+// sagaTimedOut is the type of the event that CheckTimeouts publishes for an instance whose step timed out.
+const sagaTimedOut = "saga timed out"
+
+// sagaRecovered is the type of the event that Recover publishes for an instance left running or compensating.
+const sagaRecovered = "saga recovered"
+
+// SagaEngine is an Observer that starts sagas on their trigger events and moves them forward on the events they await.
+type SagaEngine struct {
+	mu         sync.Mutex
+	bus        *EventBus
+	store      SagaStore
+	sagas      map[string]SagaDefinition
+	subscribed map[string]bool
+	now        func() time.Time
+}
+
+// NewSagaEngine creates a new SagaEngine that persists its instances in store.
+func NewSagaEngine(bus *EventBus, store SagaStore) *SagaEngine {
+	e := &SagaEngine{
+		bus:        bus,
+		store:      store,
+		sagas:      map[string]SagaDefinition{},
+		subscribed: map[string]bool{sagaTimedOut: true, sagaRecovered: true},
+		now:        time.Now,
+	}
+	bus.Subscribe(sagaTimedOut, e)
+	bus.Subscribe(sagaRecovered, e)
+	return e
+}
+
+// Register registers a saga and subscribes the engine to the events it depends on.
+func (e *SagaEngine) Register(saga SagaDefinition) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.sagas[saga.Name] = saga
+
+	eventTypes := []string{saga.Trigger}
+	for _, step := range saga.Steps {
+		eventTypes = append(eventTypes, step.Await, step.FailOn)
+	}
+	for _, eventType := range eventTypes {
+		if eventType != "" && !e.subscribed[eventType] {
+			e.subscribed[eventType] = true
+			e.bus.Subscribe(eventType, e)
+		}
+	}
+}
+
+// Update starts the sagas triggered by the event, and moves forward the saga instance the event belongs to.
+func (e *SagaEngine) Update(event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, saga := range e.sagas {
+		if saga.Trigger == event.Type {
+			if err := e.start(saga, event); err != nil {
+				log.Printf("saga %s: start: %v", saga.Name, err)
+			}
+		}
+	}
+
+	if event.CorrelationID == "" {
+		return
+	}
+	instance, err := e.store.Load(event.CorrelationID)
+	if err != nil || (instance.Status != SagaRunning && instance.Status != SagaCompensating) {
+		return
+	}
+	saga, ok := e.sagas[instance.Saga]
+	if !ok || instance.Step >= len(saga.Steps) {
+		log.Printf("saga %s %s: saga is not registered", instance.Saga, instance.ID)
+		return
+	}
+	step := saga.Steps[instance.Step]
+
+	switch {
+	case instance.Status == SagaCompensating:
+		// Only a recovery resumes a compensation: every other event arrived while it was running.
+		if event.Type == sagaRecovered {
+			err = e.undo(saga, instance)
+		}
+	case event.Type == sagaRecovered:
+		err = e.run(saga, instance)
+	case event.Type == sagaTimedOut:
+		// The instance may have moved on since the timeout was detected.
+		if !instance.Deadline.IsZero() && !e.now().Before(instance.Deadline) {
+			err = e.compensate(saga, instance, true, fmt.Sprintf("step %s timed out", step.Name))
+		}
+	case event.Type == step.Await:
+		instance.Step++
+		err = e.run(saga, instance)
+	case event.Type == step.FailOn:
+		err = e.compensate(saga, instance, false, fmt.Sprintf("step %s failed: %s", step.Name, event.Type))
+	}
+	if err != nil {
+		log.Printf("saga %s %s: %v", saga.Name, instance.ID, err)
+	}
+}
+
+// CheckTimeouts fails the steps whose deadline has passed. The instances are compensated by the engine when it
+// receives the timeout events published on the bus, so the events published by the compensating actions are
+// delivered like any other event, after the engine has released its lock.
+func (e *SagaEngine) CheckTimeouts() error {
+	e.mu.Lock()
+	running, err := e.store.Running()
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	var timedOut []string
+	var errs []error
+	for _, instance := range running {
+		if instance.Status != SagaRunning || instance.Deadline.IsZero() || e.now().Before(instance.Deadline) {
+			continue
+		}
+		if _, ok := e.sagas[instance.Saga]; !ok {
+			errs = append(errs, fmt.Errorf("saga %s %s: saga is not registered", instance.Saga, instance.ID))
+			continue
+		}
+		timedOut = append(timedOut, instance.ID)
+	}
+	e.mu.Unlock()
+
+	for _, id := range timedOut {
+		e.bus.Publish(Event{Type: sagaTimedOut, CorrelationID: id})
+	}
+	return errors.Join(errs...)
+}
+
+// Recover resumes the instances that a previous engine left running or compensating, for example because it crashed.
+// The current step of a running instance is run again, and a compensating instance goes on compensating from the
+// step it had reached, so actions and compensating actions must be idempotent. As with CheckTimeouts, the instances
+// are resumed by the engine when it receives the events published on the bus. Recover must be called once the sagas
+// are registered.
+func (e *SagaEngine) Recover() error {
+	e.mu.Lock()
+	running, err := e.store.Running()
+	if err != nil {
+		e.mu.Unlock()
+		return err
+	}
+	var recovered []string
+	var errs []error
+	for _, instance := range running {
+		if _, ok := e.sagas[instance.Saga]; !ok {
+			errs = append(errs, fmt.Errorf("saga %s %s: saga is not registered", instance.Saga, instance.ID))
+			continue
+		}
+		recovered = append(recovered, instance.ID)
+	}
+	e.mu.Unlock()
+
+	sort.Strings(recovered)
+	for _, id := range recovered {
+		e.bus.Publish(Event{Type: sagaRecovered, CorrelationID: id})
+	}
+	return errors.Join(errs...)
+}
+
+// Run checks the timeouts every interval until ctx is cancelled.
+func (e *SagaEngine) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if err := e.CheckTimeouts(); err != nil {
+				log.Printf("saga: check timeouts: %v", err)
+			}
+		}
+	}
+}
+
+// start creates a new instance of a saga and runs its first step. The caller must hold e.mu.
+func (e *SagaEngine) start(saga SagaDefinition, event Event) error {
+	id, err := newSagaID()
+	if err != nil {
+		return err
+	}
+	instance := &SagaInstance{ID: id, Saga: saga.Name, Status: SagaRunning, EntityID: event.EntityID}
+	return e.run(saga, instance)
+}
+
+// run runs the steps of an instance from its current step until a step awaits an event. The caller must hold e.mu.
+func (e *SagaEngine) run(saga SagaDefinition, instance *SagaInstance) error {
+	for ; instance.Step < len(saga.Steps); instance.Step++ {
+		step := saga.Steps[instance.Step]
+		instance.Deadline = time.Time{}
+		if step.Timeout > 0 {
+			instance.Deadline = e.now().Add(step.Timeout)
+		}
+		// The instance is saved before the action runs, so the events the action causes find it in its new step.
+		if err := e.store.Save(instance); err != nil {
+			return err
+		}
+
+		if err := step.Action(instance); err != nil {
+			return e.compensate(saga, instance, false, fmt.Sprintf("step %s failed: %v", step.Name, err))
+		}
+		if step.Await != "" {
+			return nil
+		}
+	}
+
+	instance.Status = SagaCompleted
+	instance.Deadline = time.Time{}
+	return e.store.Save(instance)
+}
+
+// compensate runs the compensating actions of the steps that took effect, in reverse order.
+// The current step is compensated too if it may have taken effect. The caller must hold e.mu.
+func (e *SagaEngine) compensate(saga SagaDefinition, instance *SagaInstance, current bool, reason string) error {
+	instance.Status = SagaCompensating
+	instance.Deadline = time.Time{}
+	instance.Error = reason
+	instance.Undo = instance.Step - 1
+	if current {
+		instance.Undo = instance.Step
+	}
+	if err := e.store.Save(instance); err != nil {
+		return err
+	}
+	return e.undo(saga, instance)
+}
+
+// undo runs the compensating actions of a compensating instance from step instance.Undo down to the first step, and
+// saves the instance after each of them, so a recovered instance does not compensate the same steps again. The
+// caller must hold e.mu.
+func (e *SagaEngine) undo(saga SagaDefinition, instance *SagaInstance) error {
+	var errs []error
+	for instance.Undo >= 0 {
+		step := saga.Steps[instance.Undo]
+		if step.Compensate != nil {
+			if err := step.Compensate(instance); err != nil {
+				errs = append(errs, fmt.Errorf("compensate %s: %w", step.Name, err))
+			}
+		}
+		instance.Undo--
+		if err := e.store.Save(instance); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+	}
+
+	instance.Status = SagaCompensated
+	if err := e.store.Save(instance); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// newSagaID returns a random saga instance ID.
+func newSagaID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// InventoryService is an Observer that reserves stock for entities, rejecting the entities in rejected.
+type InventoryService struct {
+	bus      *EventBus
+	rejected map[int]bool
+}
+
+// Update replies to reservation requests.
+func (s *InventoryService) Update(event Event) {
+	reply := Event{Type: "reserved", CorrelationID: event.CorrelationID, EntityID: event.EntityID}
+	if s.rejected[event.EntityID] {
+		reply.Type = "reservation rejected"
+	}
+	fmt.Printf("InventoryService: %s entity %d\n", reply.Type, event.EntityID)
+	s.bus.Publish(reply)
+}
+
+// ConfirmationService is an Observer that confirms entities, never answering for the entities in silent.
+type ConfirmationService struct {
+	bus    *EventBus
+	silent map[int]bool
+}
+
+// Update replies to confirmation requests.
+func (s *ConfirmationService) Update(event Event) {
+	if s.silent[event.EntityID] {
+		fmt.Printf("ConfirmationService: no answer for entity %d\n", event.EntityID)
+		return
+	}
+	fmt.Printf("ConfirmationService: confirmed entity %d\n", event.EntityID)
+	s.bus.Publish(Event{Type: "confirmed", CorrelationID: event.CorrelationID, EntityID: event.EntityID})
+}
+
+func main() {
+	bus := NewEventBus()
+	store := NewMemorySagaStore()
+	engine := NewSagaEngine(bus, store)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine.now = func() time.Time { return now }
+
+	bus.Subscribe("reserve requested", &InventoryService{bus: bus, rejected: map[int]bool{2: true}})
+	bus.Subscribe("confirm requested", &ConfirmationService{bus: bus, silent: map[int]bool{3: true}})
+
+	command := func(eventType string) func(*SagaInstance) error {
+		return func(instance *SagaInstance) error {
+			bus.Publish(Event{Type: eventType, CorrelationID: instance.ID, EntityID: instance.EntityID})
+			return nil
+		}
+	}
+	release := func(instance *SagaInstance) error {
+		fmt.Printf("Saga: releasing reservation of entity %d\n", instance.EntityID)
+		return nil
+	}
+
+	engine.Register(SagaDefinition{
+		Name:    "entity onboarding",
+		Trigger: "entity created",
+		Steps: []Step{
+			{Name: "reserve", Action: command("reserve requested"), Await: "reserved", FailOn: "reservation rejected", Timeout: time.Minute, Compensate: release},
+			{Name: "confirm", Action: command("confirm requested"), Await: "confirmed", Timeout: time.Minute},
+		},
+	})
+
+	for id := 1; id <= 3; id++ {
+		bus.Publish(Event{Type: "entity created", EntityID: id})
+	}
+
+	now = now.Add(2 * time.Minute)
+	if err := engine.CheckTimeouts(); err != nil {
+		log.Fatal(err)
+	}
+
+	// An engine that crashed while compensating left this instance behind; Recover finishes the compensation.
+	crashed := &SagaInstance{ID: "crashed", Saga: "entity onboarding", Status: SagaCompensating, Step: 1, EntityID: 4,
+		Error: "step confirm failed: engine crashed", Undo: 0}
+	if err := store.Save(crashed); err != nil {
+		log.Fatal(err)
+	}
+	if err := engine.Recover(); err != nil {
+		log.Fatal(err)
+	}
+
+	var instances []*SagaInstance
+	for id := range store.instances {
+		instance, err := store.Load(id)
+		if err != nil {
+			log.Fatal(err)
+		}
+		instances = append(instances, instance)
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].EntityID < instances[j].EntityID })
+	for _, instance := range instances {
+		fmt.Printf("Saga for entity %d: %s %s\n", instance.EntityID, instance.Status, instance.Error)
+	}
+}
+
+`
+In this example, the SagaDefinition and Step structs describe a workflow that is started by an "entity created" event, reserves stock for the entity, and then confirms it. The EventBus, SagaEngine and MemorySagaStore structs belong to the infrastructure layer, and the InventoryService and ConfirmationService structs are observers standing in for other services. The main function is the outer layer, and it creates three entities, and recovers a fourth one left behind by a crash: the first one goes through the whole workflow, the reservation of the second one is rejected, and the confirmation of the third one never arrives.
+
+The SagaEngine struct is an observer of the event bus. When it receives the trigger event of a saga, it creates a SagaInstance and runs its steps until a step awaits an event. When it receives the awaited event of the current step, it moves on to the next step, and when it receives the failure event of the current step, or when CheckTimeouts finds that the current step has passed its deadline, it runs the compensating actions of the steps that took effect in reverse order. A step that timed out may have taken effect without answering, so it is compensated too. The instance is saved in the SagaStore after every transition, and while it is compensating, after every compensating action, with the index of the next step to compensate. When the engine restarts, the Recover method resumes the instances left running or compensating: the current step of a running instance is run again, and a compensating instance goes on from the step it had reached, as for the fourth entity in the example, whose engine crashed in the middle of its compensation. A crash may happen after an action has taken effect but before the instance was saved, so actions and compensating actions must be idempotent. CheckTimeouts does not compensate the instances itself: it publishes a timeout event for each of them on the bus, so the compensating actions run like any other transition, and the events they publish are queued instead of re-entering the engine while it holds its lock. An instance whose saga is not registered (for example, after a restart that registers the sagas late) is reported as an error instead of being run.
+
+The EventBus struct queues the events published while another event is being delivered, so a service that answers a command immediately does not re-enter the engine while it is still running a step.
+
+This pattern allows a workflow that spans several services to stay consistent without distributed transactions, as every step is either completed or undone.
+`
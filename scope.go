@@ -0,0 +1,277 @@
+`
+Scoped lifetimes extend the singleton pattern to instances that should be unique within a scope rather than within the whole process, such as a unit of work or a logger per request. Instances are created lazily on first use in a scope, shared by everything that runs in that scope, and disposed when the scope ends. The global singletons live in the outermost scope, which lasts as long as the process.
+`
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Lifetime determines in which scope the instance of a key lives.
+type Lifetime int
+
+const (
+	// Singleton instances live in the root scope and are shared by the whole process.
+	Singleton Lifetime = iota
+	// Scoped instances live in the innermost scope of the context they are resolved in.
+	Scoped
+)
+
+// ErrNoScope is returned when a scoped instance is resolved outside a scope, including while a singleton is built.
+var ErrNoScope = errors.New("scoped instance resolved outside a scope")
+
+// ErrScopeClosed is returned when an instance is resolved in a scope that has ended.
+var ErrScopeClosed = errors.New("scope is closed")
+
+// Key identifies an instance and describes how to build it.
+type Key[T any] struct {
+	name     string
+	lifetime Lifetime
+	build    func(ctx context.Context) (T, error)
+}
+
+// NewKey creates a new Key for instances of type T with the given lifetime.
+// The build function can resolve the instances it depends on from ctx.
+func NewKey[T any](name string, lifetime Lifetime, build func(ctx context.Context) (T, error)) *Key[T] {
+	return &Key[T]{name: name, lifetime: lifetime, build: build}
+}
+
+// scopedEntry is an instance of a scope, or the build of that instance in progress.
+type scopedEntry struct {
+	ready chan struct{}
+	value any
+	err   error
+}
+
+// Scope holds the instances created within it.
+type Scope struct {
+	mu        sync.Mutex
+	instances map[any]*scopedEntry
+	created   []any
+	closed    bool
+}
+
+// rootScope is the outermost scope, which holds the singletons.
+var rootScope = &Scope{instances: map[any]*scopedEntry{}}
+
+// scopeKey is the context key of the current scope.
+type scopeKey struct{}
+
+// WithScope returns a copy of ctx with a new scope, which replaces the current scope of ctx for scoped instances.
+// The caller must close the scope when the unit of work it represents ends.
+func WithScope(ctx context.Context) (context.Context, *Scope) {
+	s := &Scope{instances: map[any]*scopedEntry{}}
+	return context.WithValue(ctx, scopeKey{}, s), s
+}
+
+// ScopeFrom returns the current scope of ctx, or the root scope if ctx has none.
+func ScopeFrom(ctx context.Context) *Scope {
+	if s, ok := ctx.Value(scopeKey{}).(*Scope); ok {
+		return s
+	}
+	return rootScope
+}
+
+// Resolve returns the instance of key, building it in its scope on first use.
+func Resolve[T any](ctx context.Context, key *Key[T]) (T, error) {
+	var zero T
+	scope := ScopeFrom(ctx)
+	if key.lifetime == Singleton {
+		scope = rootScope
+		// Singletons are built in the root scope, so they cannot capture an instance of a shorter-lived scope.
+		ctx = context.WithValue(ctx, scopeKey{}, rootScope)
+	} else if scope == rootScope {
+		return zero, fmt.Errorf("resolve %s: %w", key.name, ErrNoScope)
+	}
+
+	scope.mu.Lock()
+	if scope.closed {
+		scope.mu.Unlock()
+		return zero, fmt.Errorf("resolve %s: %w", key.name, ErrScopeClosed)
+	}
+	e, ok := scope.instances[key]
+	if !ok {
+		e = &scopedEntry{ready: make(chan struct{})}
+		scope.instances[key] = e
+	}
+	scope.mu.Unlock()
+
+	if !ok {
+		buildScoped(ctx, scope, key, e)
+	}
+
+	<-e.ready
+	if e.err != nil {
+		return zero, e.err
+	}
+	// A nil instance of an interface type is stored as a nil any, which only the comma-ok form converts back.
+	v, _ := e.value.(T)
+	return v, nil
+}
+
+// buildScoped builds the instance of key for e in scope, and releases the callers waiting for it.
+// A panic of the build function is recorded as an error, like an error it returns.
+func buildScoped[T any](ctx context.Context, scope *Scope, key *Key[T], e *scopedEntry) {
+	defer close(e.ready)
+	defer func() {
+		if r := recover(); r != nil {
+			e.err = fmt.Errorf("build %s: panic: %v", key.name, r)
+		}
+	}()
+
+	value, err := key.build(ctx)
+	if err != nil {
+		e.err = fmt.Errorf("build %s: %w", key.name, err)
+		return
+	}
+
+	scope.mu.Lock()
+	closed := scope.closed
+	if !closed {
+		scope.created = append(scope.created, value)
+	}
+	scope.mu.Unlock()
+
+	if closed {
+		// The scope ended while the instance was built, so nothing else would ever close it.
+		e.err = fmt.Errorf("resolve %s: %w", key.name, ErrScopeClosed)
+		if c, ok := any(value).(io.Closer); ok {
+			e.err = errors.Join(e.err, c.Close())
+		}
+		return
+	}
+	e.value = value
+}
+
+// Close ends the scope and closes the instances created in it that implement io.Closer, in reverse order of creation.
+func (s *Scope) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	created := s.created
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(created) - 1; i >= 0; i-- {
+		if c, ok := created[i].(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DBPool is a pool of database connections shared by the whole process.
+type DBPool struct{}
+
+// Close closes the connections of the pool.
+func (p *DBPool) Close() error {
+	fmt.Println("Closing DBPool")
+	return nil
+}
+
+// RequestLogger is a logger that prefixes its messages with the ID of the request.
+type RequestLogger struct {
+	requestID string
+}
+
+// Log logs a message.
+func (l *RequestLogger) Log(message string) {
+	fmt.Printf("[%s] %s\n", l.requestID, message)
+}
+
+// UnitOfWork collects the changes of a request and commits them when the request ends.
+type UnitOfWork struct {
+	db      *DBPool
+	logger  *RequestLogger
+	changes []string
+}
+
+// Add records a change.
+func (u *UnitOfWork) Add(change string) {
+	u.changes = append(u.changes, change)
+}
+
+// Close commits the recorded changes.
+func (u *UnitOfWork) Close() error {
+	u.logger.Log(fmt.Sprintf("committing %d changes", len(u.changes)))
+	return nil
+}
+
+// requestIDKey is the context key of the request ID.
+type requestIDKey struct{}
+
+var (
+	dbPoolKey = NewKey("db pool", Singleton, func(ctx context.Context) (*DBPool, error) {
+		return &DBPool{}, nil
+	})
+	loggerKey = NewKey("request logger", Scoped, func(ctx context.Context) (*RequestLogger, error) {
+		id, _ := ctx.Value(requestIDKey{}).(string)
+		return &RequestLogger{requestID: id}, nil
+	})
+	unitOfWorkKey = NewKey("unit of work", Scoped, func(ctx context.Context) (*UnitOfWork, error) {
+		db, err := Resolve(ctx, dbPoolKey)
+		if err != nil {
+			return nil, err
+		}
+		logger, err := Resolve(ctx, loggerKey)
+		if err != nil {
+			return nil, err
+		}
+		return &UnitOfWork{db: db, logger: logger}, nil
+	})
+)
+
+// handleRequest handles a request in its own scope.
+func handleRequest(ctx context.Context, id string, changes ...string) error {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	ctx, scope := WithScope(ctx)
+	defer scope.Close()
+
+	for _, change := range changes {
+		uow, err := Resolve(ctx, unitOfWorkKey)
+		if err != nil {
+			return err
+		}
+		uow.Add(change)
+	}
+
+	logger, err := Resolve(ctx, loggerKey)
+	if err != nil {
+		return err
+	}
+	logger.Log("handled")
+	return nil
+}
+
+func main() {
+	ctx := context.Background()
+
+	handleRequest(ctx, "request-1", "rename entity")
+	handleRequest(ctx, "request-2", "create entity", "rename entity")
+
+	if _, err := Resolve(ctx, unitOfWorkKey); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	rootScope.Close()
+}
+
+`
+In this example, the DBPool, RequestLogger and UnitOfWork structs are the components of the application, and the Scope struct and the Key type are part of the infrastructure layer. The main function is the outer layer, and it handles two requests, each in its own scope, and closes the root scope when the process exits.
+
+Every Key has a lifetime. Singleton instances are built once in the root scope, which is the global singleton of the process, while Scoped instances are built once in the innermost scope attached to the context they are resolved from. The WithScope function attaches a new scope to a context, and everything that resolves instances from that context (or from a context derived from it) shares the instances of the scope, so both changes of the second request are added to the same unit of work. Closing the scope closes the instances created in it in reverse order of creation, which commits the unit of work at the end of the request. An instance whose build finishes after its scope has been closed is closed at once, and Resolve returns ErrScopeClosed, so it is not leaked; a build that panics is reported as an error, so the callers waiting for it are released.
+
+A singleton lives longer than any scope, so it must not hold on to a scoped instance. Singletons are therefore built with the root scope as the current scope, and resolving a scoped instance there fails with ErrNoScope, as it does in the main function outside a request.
+
+This pattern allows instances that should be unique per request to be shared within the request without being passed through every function, while guaranteeing that they are disposed of when the request ends.
+`
@@ -4,24 +4,211 @@ The singleton pattern is a creational pattern that ensures that a class has only
 
 package main
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
 
 // Singleton is a class that implements the singleton pattern.
 type Singleton struct{}
 
-// instance is the singleton instance.
-var instance *Singleton
+var (
+	// instance is the singleton instance.
+	instance *Singleton
+	// once guards the creation of instance.
+	once sync.Once
+	// override replaces instance during a test.
+	override atomic.Pointer[Singleton]
+)
 
 // GetInstance returns the singleton instance.
+// It is safe to call from several goroutines: the instance is created exactly once.
 func GetInstance() *Singleton {
-	if instance == nil {
-		instance = &Singleton{}
+	if s := override.Load(); s != nil {
+		return s
 	}
+	once.Do(func() {
+		instance = &Singleton{}
+	})
 	return instance
 }
 
+// OverrideInstance makes GetInstance return s until the end of the test.
+// It panics when called outside a test binary.
+func OverrideInstance(t testing.TB, s *Singleton) {
+	mustBeTesting("OverrideInstance")
+	previous := override.Swap(s)
+	t.Cleanup(func() {
+		override.Store(previous)
+	})
+}
+
+// ResetInstance discards the singleton instance, so the next call to GetInstance creates a new one.
+// It must not be called while other goroutines call GetInstance, and it panics when called outside a test binary.
+func ResetInstance() {
+	mustBeTesting("ResetInstance")
+	once = sync.Once{}
+	instance = nil
+}
+
+// mustBeTesting panics if the program is not a test binary, so test hooks cannot be used in production.
+func mustBeTesting(name string) {
+	if !testing.Testing() {
+		panic(fmt.Sprintf("singleton: %s called outside a test binary", name))
+	}
+}
+
+// Lazy is a singleton whose initializer can fail.
+// The initializer runs on the first call to Get, and every caller waiting for that run gets its result.
+// If retry is false, the result is kept forever, including an error. If retry is true, an error is not kept,
+// so the next call to Get runs the initializer again. A panic of the initializer is treated as an error.
+type Lazy[T any] struct {
+	mu         sync.Mutex
+	init       func() (T, error)
+	retry      bool
+	done       bool
+	value      T
+	pending    *attempt[T]
+	overridden bool
+	override   T
+}
+
+// attempt is a run of the initializer of a Lazy, shared by every caller waiting for it.
+type attempt[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+// NewLazy creates a new Lazy singleton with the given initializer.
+func NewLazy[T any](init func() (T, error), retry bool) *Lazy[T] {
+	return &Lazy[T]{init: init, retry: retry}
+}
+
+// Get returns the singleton instance, running the initializer if needed.
+func (l *Lazy[T]) Get() (T, error) {
+	l.mu.Lock()
+	if l.overridden {
+		defer l.mu.Unlock()
+		return l.override, nil
+	}
+	if l.done {
+		l.mu.Unlock()
+		return l.value, nil
+	}
+	if a := l.pending; a != nil {
+		l.mu.Unlock()
+		<-a.done
+		return a.value, a.err
+	}
+
+	a := &attempt[T]{done: make(chan struct{})}
+	l.pending = a
+	l.mu.Unlock()
+
+	a.value, a.err = l.run()
+
+	l.mu.Lock()
+	// The attempt is no longer pending if the singleton was reset while it was running.
+	if l.pending == a {
+		if a.err == nil {
+			l.done = true
+			l.value = a.value
+			l.pending = nil
+		} else if l.retry {
+			l.pending = nil
+		}
+	}
+	l.mu.Unlock()
+	close(a.done)
+
+	return a.value, a.err
+}
+
+// run runs the initializer. A panic is returned as an error, so the callers waiting for the attempt are released
+// and, if retry is true, the next call to Get runs the initializer again.
+func (l *Lazy[T]) run() (value T, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("singleton: initializer panicked: %v", r)
+		}
+	}()
+	return l.init()
+}
+
+// Override makes Get return value until the end of the test, without running the initializer.
+// Overrides can be nested. It panics when called outside a test binary.
+func (l *Lazy[T]) Override(t testing.TB, value T) {
+	mustBeTesting("Lazy.Override")
+	l.mu.Lock()
+	previous, overridden := l.override, l.overridden
+	l.override, l.overridden = value, true
+	l.mu.Unlock()
+
+	t.Cleanup(func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		l.override, l.overridden = previous, overridden
+	})
+}
+
+// Reset discards the instance or the kept error, so the next call to Get runs the initializer again.
+// A run of the initializer in progress is not interrupted, but its result is not kept.
+// It panics when called outside a test binary.
+func (l *Lazy[T]) Reset() {
+	mustBeTesting("Lazy.Reset")
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var zero T
+	l.done = false
+	l.value = zero
+	l.pending = nil
+}
+
+// Config is the configuration of the application.
+type Config struct {
+	Name string `json:"name"`
+}
+
+// configPath is the path of the configuration file.
+var configPath = filepath.Join(os.TempDir(), "singleton-config.json")
+
+// config is the configuration singleton. A missing file is retried on the next call.
+var config = NewLazy(func() (*Config, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("load config: %w", err)
+	}
+	return &c, nil
+}, true)
+
+// GetConfig returns the configuration singleton.
+func GetConfig() (*Config, error) {
+	return config.Get()
+}
+
 func main() {
-	s1 := GetInstance()
+	instances := make([]*Singleton, 10)
+	var wg sync.WaitGroup
+	for i := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			instances[i] = GetInstance()
+		}()
+	}
+	wg.Wait()
+
+	s1 := instances[0]
 	s2 := GetInstance()
 
 	if s1 == s2 {
@@ -29,12 +216,37 @@ func main() {
 	} else {
 		fmt.Println("s1 and s2 are different instances")
 	}
+
+	os.Remove(configPath)
+	if _, err := GetConfig(); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	os.WriteFile(configPath, []byte(`{"name": "My App"}`), 0o644)
+	defer os.Remove(configPath)
+	c, err := GetConfig()
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Config:", c.Name)
+
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("Error:", r)
+		}
+	}()
+	ResetInstance()
 }
 
 
 
 `
-In this example, the main function depends on the inner layer (Singleton) to get the singleton instance. The GetInstance function ensures that only one instance of the Singleton struct is created, and it returns the same instance every time it is called.
+In this example, the main function depends on the inner layer (Singleton) to get the singleton instance. The GetInstance function ensures that only one instance of the Singleton struct is created, and it returns the same instance every time it is called. The creation is guarded by a sync.Once, so goroutines that call GetInstance at the same time cannot create two instances.
+
+The Lazy struct is a singleton whose initializer can fail, such as the Config singleton, which is loaded from a file. The initializer runs on the first call to Get, and callers that arrive while it is running wait for it and get the same result, including the same error. When the Lazy singleton is created with retry set to true, an error is not kept, so the next call runs the initializer again (in the example, once the configuration file has been written); otherwise the error is returned to every later caller as well. A panic of the initializer is turned into an error in the same way, so the callers waiting for it are not blocked forever.
+
+The OverrideInstance function and the Override method of the Lazy struct replace a singleton instance with a mock object until the end of a test: they take the testing.TB of the test and restore the previous instance with t.Cleanup, so a test cannot leak its mock into the next one. The ResetInstance function and the Reset method discard the instance, so the next caller creates a new one. All of these panic when called outside a test binary (as the main function shows), so production code cannot use them to swap a singleton behind the back of its callers.
 
-This pattern can be useful when you want to ensure that a class has only one instance, and you want to provide a global access point to that instance. However, it can make it difficult to test the code that depends on the singleton, as the singleton instance cannot be replaced with a mock object during testing.
+This pattern can be useful when you want to ensure that a class has only one instance, and you want to provide a global access point to that instance. However, it can make it difficult to test the code that depends on the singleton, as every test shares the same instance unless it explicitly overrides or resets it.
 `
@@ -0,0 +1,239 @@
+`
+A singleton registry is a variation of the singleton pattern that manages many singletons in one place. Every singleton is identified by its type and created lazily on first use, and the registry remembers the order in which they were created, so it can shut them down in reverse order when the process exits.
+`
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// ErrRegistryClosed is returned when a singleton is requested from a registry that has been shut down.
+var ErrRegistryClosed = errors.New("registry is shut down")
+
+// entry is a singleton of a registry.
+type entry struct {
+	once  sync.Once
+	build func(r *Registry) (any, error)
+	value any
+	err   error
+}
+
+// Registry is a set of singletons keyed by their type.
+type Registry struct {
+	mu      sync.Mutex
+	entries map[reflect.Type]*entry
+	created []any
+	closed  bool
+}
+
+// NewRegistry creates a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{entries: map[reflect.Type]*entry{}}
+}
+
+// Register registers the function that builds the singleton of type T.
+// The function can get the singletons it depends on from the registry. Registering a type again replaces the
+// previous function, and the next call to Get builds a new singleton.
+func Register[T any](r *Registry, build func(r *Registry) (T, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries[reflect.TypeFor[T]()] = &entry{build: func(r *Registry) (any, error) {
+		return build(r)
+	}}
+}
+
+// Get returns the singleton of type T, building it on first use.
+// If building fails, the error is returned to every caller.
+func Get[T any](r *Registry) (T, error) {
+	var zero T
+	t := reflect.TypeFor[T]()
+
+	r.mu.Lock()
+	e, ok := r.entries[t]
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return zero, ErrRegistryClosed
+	}
+	if !ok {
+		return zero, fmt.Errorf("no singleton registered for %v", t)
+	}
+
+	e.once.Do(func() {
+		e.value, e.err = buildEntry(r, t, e)
+	})
+	if e.err != nil {
+		return zero, e.err
+	}
+	// A nil instance of an interface type is stored as a nil any, which only the comma-ok form converts back.
+	v, _ := e.value.(T)
+	return v, nil
+}
+
+// buildEntry builds the singleton of type t for e, and records it so Shutdown closes it.
+// A panic of the build function is returned as an error, like an error it returns, and a singleton built after
+// Shutdown has started is closed at once, since Shutdown would never see it.
+func buildEntry(r *Registry, t reflect.Type, e *entry) (value any, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			value, err = nil, fmt.Errorf("build %v: panic: %v", t, p)
+		}
+	}()
+
+	value, err = e.build(r)
+	if err != nil {
+		return nil, fmt.Errorf("build %v: %w", t, err)
+	}
+
+	r.mu.Lock()
+	closed := r.closed
+	if !closed {
+		r.created = append(r.created, value)
+	}
+	r.mu.Unlock()
+
+	if closed {
+		err = ErrRegistryClosed
+		if c, ok := value.(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+		return nil, err
+	}
+	return value, nil
+}
+
+// MustGet returns the singleton of type T, panicking if it cannot be built.
+func MustGet[T any](r *Registry) T {
+	v, err := Get[T](r)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Shutdown closes every singleton that implements io.Closer, in the reverse order of their creation,
+// so a singleton is closed before the singletons it depends on. It returns the errors of every Close that failed.
+// If ctx is done before every singleton has been closed, Shutdown stops waiting and reports the singletons left open.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return ErrRegistryClosed
+	}
+	r.closed = true
+	created := r.created
+	r.mu.Unlock()
+
+	var errs []error
+	for i := len(created) - 1; i >= 0; i-- {
+		closer, ok := created[i].(io.Closer)
+		if !ok {
+			continue
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- closer.Close()
+		}()
+
+		select {
+		case err := <-done:
+			if err != nil {
+				errs = append(errs, fmt.Errorf("close %T: %w", closer, err))
+			}
+		case <-ctx.Done():
+			for ; i >= 0; i-- {
+				if _, ok := created[i].(io.Closer); ok {
+					errs = append(errs, fmt.Errorf("close %T: %w", created[i], ctx.Err()))
+				}
+			}
+			return errors.Join(errs...)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DBPool is a pool of database connections.
+type DBPool struct{}
+
+// Close closes the connections of the pool.
+func (p *DBPool) Close() error {
+	fmt.Println("Closing DBPool")
+	return nil
+}
+
+// Logger is a logger that buffers its messages.
+type Logger struct{}
+
+// Log logs a message.
+func (l *Logger) Log(message string) {
+	fmt.Println("Logger:", message)
+}
+
+// Close flushes the buffered messages.
+func (l *Logger) Close() error {
+	fmt.Println("Closing Logger")
+	return nil
+}
+
+// Cache is a cache in front of the database.
+type Cache struct {
+	db *DBPool
+}
+
+// Close writes the dirty entries of the cache back to the database.
+func (c *Cache) Close() error {
+	fmt.Println("Closing Cache")
+	return errors.New("2 dirty entries lost")
+}
+
+// registry holds the singletons of the process.
+var registry = NewRegistry()
+
+func main() {
+	Register(registry, func(r *Registry) (*DBPool, error) {
+		MustGet[*Logger](r).Log("opening DBPool")
+		return &DBPool{}, nil
+	})
+	Register(registry, func(r *Registry) (*Logger, error) {
+		return &Logger{}, nil
+	})
+	Register(registry, func(r *Registry) (*Cache, error) {
+		db, err := Get[*DBPool](r)
+		if err != nil {
+			return nil, err
+		}
+		return &Cache{db: db}, nil
+	})
+
+	c1 := MustGet[*Cache](registry)
+	c2 := MustGet[*Cache](registry)
+	fmt.Println("c1 and c2 are the same instance:", c1 == c2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := registry.Shutdown(ctx); err != nil {
+		fmt.Println("Error:", err)
+	}
+
+	if _, err := Get[*Cache](registry); err != nil {
+		fmt.Println("Error:", err)
+	}
+}
+
+`
+In this example, the DBPool, Logger and Cache structs are singletons of the application, and the Registry struct is part of the infrastructure layer. The main function is the outer layer, and it registers how to build each singleton, gets the cache (which builds the logger, the database pool and the cache, in that order), and shuts the registry down.
+
+The Register function associates a type with the function that builds its singleton, and the Get function builds the singleton of a type on first use and returns the same instance on every later call. Building a singleton can get the singletons it depends on from the registry, so the dependencies are always built (and recorded as created) before the singletons that use them. Building a singleton must not depend on the singleton itself, directly or indirectly.
+
+The Shutdown method closes every singleton that implements io.Closer in the reverse order of creation, so the cache is closed before the database pool it writes to, and the logger is closed last. The errors of every Close are aggregated with errors.Join, and if the context expires before every singleton has been closed, Shutdown reports the singletons left open instead of blocking the exit of the process. Once the registry is shut down, Get returns ErrRegistryClosed, and a singleton whose build was still running when Shutdown started is closed as soon as it is built, so it is not left open. A build that panics is reported as an error to every caller, like a build that fails.
+
+This pattern allows the process-wide singletons to be created and destroyed in a predictable order, without every singleton having its own GetInstance function and its own shutdown code.
+`
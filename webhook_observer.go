@@ -0,0 +1,384 @@
+`
+A webhook observer is an observer that forwards the state changes of a subject to external systems over HTTP. Every state change is sent as a signed JSON request to the configured endpoints, failed deliveries are retried with an exponential backoff, and every delivery attempt is recorded.
+`
+
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the request header carrying the HMAC-SHA256 signature of the body, as "sha256=<hex>".
+const SignatureHeader = "X-Webhook-Signature"
+
+// EventIDHeader is the request header carrying the ID of the event, which is the same for every attempt.
+const EventIDHeader = "X-Webhook-Event-ID"
+
+// Observer is an interface for observers.
+type Observer interface {
+	Update(state string)
+}
+
+// ConcreteSubject is a subject that notifies its observers of every state change.
+type ConcreteSubject struct {
+	observers []Observer
+	state     string
+}
+
+// Attach attaches an observer to the subject.
+func (s *ConcreteSubject) Attach(observer Observer) {
+	s.observers = append(s.observers, observer)
+}
+
+// Notify notifies all attached observers of the current state.
+func (s *ConcreteSubject) Notify() {
+	for _, o := range s.observers {
+		o.Update(s.state)
+	}
+}
+
+// SetState sets the state of the subject.
+func (s *ConcreteSubject) SetState(state string) {
+	s.state = state
+}
+
+// WebhookEvent is the JSON body of a webhook request.
+type WebhookEvent struct {
+	ID    string    `json:"id"`
+	State string    `json:"state"`
+	Time  time.Time `json:"time"`
+}
+
+// Endpoint is an HTTP endpoint that receives webhook requests, with the secret used to sign them.
+type Endpoint struct {
+	URL    string
+	Secret []byte
+}
+
+// DeliveryAttempt records an attempt to deliver an event to an endpoint.
+type DeliveryAttempt struct {
+	EventID    string
+	URL        string
+	Attempt    int
+	StatusCode int
+	Err        string
+	Duration   time.Duration
+	Time       time.Time
+}
+
+// WebhookOptions configures a WebhookObserver.
+type WebhookOptions struct {
+	// Client is the HTTP client used to send the requests. It defaults to a client with a 10 second timeout.
+	Client *http.Client
+	// MaxAttempts is the number of attempts per endpoint before a delivery is given up.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles after every retry.
+	Backoff time.Duration
+	// QueueSize is the number of events waiting for delivery before Update starts dropping them. It defaults to 100.
+	QueueSize int
+	// AttemptHistory is the number of recent delivery attempts kept for Attempts. It defaults to 100.
+	AttemptHistory int
+}
+
+// WebhookObserver is an Observer that POSTs every state change as JSON to its endpoints.
+// The deliveries run on a worker goroutine, so a slow or failing endpoint does not block the subject.
+type WebhookObserver struct {
+	endpoints []Endpoint
+	opts      WebhookOptions
+	sleep     func(time.Duration)
+	now       func() time.Time
+	queue     chan WebhookEvent
+	done      chan struct{}
+
+	mu sync.Mutex
+	// attempts is a ring of the last AttemptHistory attempts, whose oldest attempt is at index next once it is full.
+	attempts []DeliveryAttempt
+	next     int
+	closed   bool
+}
+
+// NewWebhookObserver creates a new WebhookObserver that delivers to endpoints.
+func NewWebhookObserver(endpoints []Endpoint, opts WebhookOptions) *WebhookObserver {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 100
+	}
+	if opts.AttemptHistory <= 0 {
+		opts.AttemptHistory = 100
+	}
+	o := &WebhookObserver{
+		endpoints: endpoints,
+		opts:      opts,
+		sleep:     time.Sleep,
+		now:       time.Now,
+		queue:     make(chan WebhookEvent, opts.QueueSize),
+		done:      make(chan struct{}),
+	}
+	go o.run()
+	return o
+}
+
+// Update queues the state for delivery to every endpoint and returns at once. If the queue is full, or the observer
+// is closed, the state is dropped and logged.
+func (o *WebhookObserver) Update(state string) {
+	event, err := o.newEvent(state)
+	if err != nil {
+		log.Printf("webhook: %v", err)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.closed {
+		log.Printf("webhook: dropping event %s: observer is closed", event.ID)
+		return
+	}
+	select {
+	case o.queue <- event:
+	default:
+		log.Printf("webhook: dropping event %s: queue is full", event.ID)
+	}
+}
+
+// run delivers the queued events in order until the observer is closed, logging the deliveries that fail.
+func (o *WebhookObserver) run() {
+	defer close(o.done)
+	for event := range o.queue {
+		if err := o.send(event); err != nil {
+			log.Printf("webhook: %v", err)
+		}
+	}
+}
+
+// Close stops accepting state changes and waits until the queued events have been delivered or given up.
+func (o *WebhookObserver) Close() {
+	o.mu.Lock()
+	if !o.closed {
+		o.closed = true
+		close(o.queue)
+	}
+	o.mu.Unlock()
+	<-o.done
+}
+
+// Deliver delivers the state to every endpoint and waits for the deliveries, returning the errors of the
+// deliveries that were given up.
+func (o *WebhookObserver) Deliver(state string) error {
+	event, err := o.newEvent(state)
+	if err != nil {
+		return err
+	}
+	return o.send(event)
+}
+
+// newEvent returns the event of a state change, with a new ID.
+func (o *WebhookObserver) newEvent(state string) (WebhookEvent, error) {
+	id, err := newEventID()
+	if err != nil {
+		return WebhookEvent{}, err
+	}
+	return WebhookEvent{ID: id, State: state, Time: o.now().UTC()}, nil
+}
+
+// send delivers event to every endpoint, returning the errors of the deliveries that were given up.
+func (o *WebhookObserver) send(event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, endpoint := range o.endpoints {
+		if err := o.deliver(endpoint, event.ID, body); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// deliver sends body to an endpoint, retrying until it succeeds or runs out of attempts.
+func (o *WebhookObserver) deliver(endpoint Endpoint, id string, body []byte) error {
+	signature := Sign(endpoint.Secret, body)
+	backoff := o.opts.Backoff
+
+	var err error
+	for attempt := 1; attempt <= o.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			o.sleep(backoff)
+			backoff *= 2
+		}
+
+		start := o.now()
+		var status int
+		status, err = o.post(endpoint.URL, id, signature, body)
+		record := DeliveryAttempt{
+			EventID:    id,
+			URL:        endpoint.URL,
+			Attempt:    attempt,
+			StatusCode: status,
+			Duration:   o.now().Sub(start),
+			Time:       start,
+		}
+		if err != nil {
+			record.Err = err.Error()
+		}
+		o.record(record)
+
+		if err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("deliver event %s to %s: giving up after %d attempts: %w", id, endpoint.URL, o.opts.MaxAttempts, err)
+}
+
+// post sends a single webhook request and returns its status code, failing on a non-2xx response.
+func (o *WebhookObserver) post(url, id, signature string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, id)
+	req.Header.Set(SignatureHeader, signature)
+
+	resp, err := o.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// record records a delivery attempt, replacing the oldest one once AttemptHistory attempts are kept.
+func (o *WebhookObserver) record(attempt DeliveryAttempt) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.attempts) < o.opts.AttemptHistory {
+		o.attempts = append(o.attempts, attempt)
+		return
+	}
+	o.attempts[o.next] = attempt
+	o.next = (o.next + 1) % len(o.attempts)
+}
+
+// Attempts returns the most recent delivery attempts, oldest first.
+func (o *WebhookObserver) Attempts() []DeliveryAttempt {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	attempts := append([]DeliveryAttempt(nil), o.attempts[o.next:]...)
+	return append(attempts, o.attempts[:o.next]...)
+}
+
+// Sign returns the signature of body with secret, in the format of the SignatureHeader.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature is a valid signature of body with secret.
+// Receivers of webhook requests use it to check that a request comes from the observer.
+func VerifySignature(secret, body []byte, signature string) bool {
+	got, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	sum, err := hex.DecodeString(got)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hmac.Equal(sum, mac.Sum(nil))
+}
+
+// newEventID returns a random event ID.
+func newEventID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func main() {
+	secret := []byte("s3cr3t")
+
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifySignature(secret, body, r.Header.Get(SignatureHeader)) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		requests++
+		failing := requests%2 == 1
+		mu.Unlock()
+		if failing {
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+			return
+		}
+
+		var event WebhookEvent
+		json.Unmarshal(body, &event)
+		fmt.Println("Receiver:", event.State)
+	}))
+	defer server.Close()
+
+	webhook := NewWebhookObserver(
+		[]Endpoint{{URL: server.URL, Secret: secret}},
+		WebhookOptions{MaxAttempts: 3, Backoff: 10 * time.Millisecond},
+	)
+
+	subject := &ConcreteSubject{}
+	subject.Attach(webhook)
+	subject.SetState("Hello")
+	subject.Notify()
+	subject.SetState("World")
+	subject.Notify()
+	webhook.Close()
+
+	for _, a := range webhook.Attempts() {
+		fmt.Printf("Attempt %d: status %d %s\n", a.Attempt, a.StatusCode, a.Err)
+	}
+}
+
+`
+In this example, the Observer interface and the ConcreteSubject struct come from the observer pattern, while the WebhookObserver struct belongs to the infrastructure layer. The main function is the outer layer, and it attaches a webhook observer to a subject and delivers two state changes to an httptest server that verifies the signature of every request and fails every other one.
+
+The WebhookObserver struct sends every state change as a WebhookEvent to each of its endpoints. The JSON body is signed with the secret of the endpoint using HMAC-SHA256, and the signature is sent in the X-Webhook-Signature header, so the receiver can check with VerifySignature that the request comes from the observer and was not modified. The event ID is sent in the X-Webhook-Event-ID header and stays the same across attempts, so the receiver can discard duplicates. A request that fails or gets a non-2xx response is retried up to MaxAttempts times, waiting Backoff before the first retry and doubling the delay after each one, and every attempt is recorded with its status code, error and duration. Only the last AttemptHistory attempts are kept, in a ring buffer, so a long-lived observer does not grow without bound.
+
+Retries with backoff can take a long time, so the Update method does not deliver the event itself: it puts the event in a queue of QueueSize events and returns at once, and a worker goroutine delivers the queued events one at a time, in order. The subject is therefore never blocked by a slow or failing endpoint; if the endpoints fall so far behind that the queue is full, new events are dropped and logged instead. The Close method stops accepting events and waits for the queued ones to be delivered, as the main function does before printing the attempts, and the Deliver method delivers a state change synchronously for callers that need to know whether it was delivered.
+
+This pattern allows external systems to observe the state of a subject without the subject knowing anything about HTTP.
+`